@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	accessFileName = flag.String("access-file", ".access", "The object name of per-directory access rules, disabled when empty")
+	accessCacheTTL = flag.Duration("access-cache-ttl", time.Minute, "How long per-directory access rules are cached")
+)
+
+// 目录访问规则，对应 .access 对象中的内容：
+//
+//	read: alice bob
+//	write: alice
+//
+// 未出现的行表示不限制，* 表示任何人
+type accessRules struct {
+	read  []string
+	write []string
+}
+
+type accessCacheEntry struct {
+	rules   *accessRules
+	expires time.Time
+}
+
+var (
+	accessCache   = map[string]accessCacheEntry{}
+	accessCacheMu sync.Mutex
+)
+
+func parseAccessRules(r *bufio.Scanner) *accessRules {
+	rules := &accessRules{}
+	for r.Scan() {
+		line := strings.TrimSpace(r.Text())
+		name, users, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, "#") {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "read":
+			rules.read = append(rules.read, strings.Fields(users)...)
+		case "write":
+			rules.write = append(rules.write, strings.Fields(users)...)
+		}
+	}
+	return rules
+}
+
+// 读取目录自身的访问规则，目录中没有规则对象时返回 nil
+func dirAccessRules(ctx context.Context, dir string) (*accessRules, error) {
+	accessCacheMu.Lock()
+	entry, ok := accessCache[dir]
+	accessCacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.rules, nil
+	}
+
+	// 挂载点之外（多存储桶时的根目录）没有规则
+	if findMount(dir+*accessFileName) == nil {
+		return nil, nil
+	}
+
+	var rules *accessRules
+	m, objectKey := resolveKey(dir + *accessFileName)
+	object, _, _, err := getObject(ctx, m, objectKey, minio.GetObjectOptions{})
+	if err == nil {
+		rules = parseAccessRules(bufio.NewScanner(object))
+		object.Close()
+	} else if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return nil, err
+	}
+
+	accessCacheMu.Lock()
+	accessCache[dir] = accessCacheEntry{rules: rules, expires: time.Now().Add(*accessCacheTTL)}
+	accessCacheMu.Unlock()
+	return rules, nil
+}
+
+// 从 key 所在目录逐级向上合并访问规则，read 与 write 分别取最近一处的设置，
+// 子目录只写了 write 时仍继承上级的 read 限制；都没有时返回 nil
+func findAccessRules(ctx context.Context, key string) (*accessRules, error) {
+	dir := key
+	if !strings.HasSuffix(dir, "/") {
+		dir = path.Dir(key) + "/"
+	}
+	var merged *accessRules
+	for {
+		if dir == "./" || dir == "/" {
+			dir = ""
+		}
+		rules, err := dirAccessRules(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		if rules != nil {
+			if merged == nil {
+				merged = &accessRules{}
+			}
+			if merged.read == nil {
+				merged.read = rules.read
+			}
+			if merged.write == nil {
+				merged.write = rules.write
+			}
+		}
+		if dir == "" || (merged != nil && merged.read != nil && merged.write != nil) {
+			return merged, nil
+		}
+		dir = path.Dir(strings.TrimSuffix(dir, "/")) + "/"
+	}
+}
+
+func resetAccessCache() {
+	accessCacheMu.Lock()
+	accessCache = map[string]accessCacheEntry{}
+	accessCacheMu.Unlock()
+}
+
+func isAccessFile(key string) bool {
+	return *accessFileName != "" && path.Base(key) == *accessFileName
+}
+
+// 按目录访问规则检查读写权限
+func checkAccess(w http.ResponseWriter, r *http.Request, key string, write bool) bool {
+	if !checkHome(w, r, key, write) {
+		return false
+	}
+	status, user, err := accessStatus(r, key, write)
+	switch status {
+	case http.StatusOK:
+		if user != "" {
+			issueSession(w, r, user)
+		}
+		return true
+	case http.StatusUnauthorized:
+		requireAuth(w, r)
+	case http.StatusInternalServerError:
+		log.Printf("访问规则读取失败: %v", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+	default:
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+	}
+	return false
+}
+
+// 不写响应的权限判断，返回状态码与规则中匹配到的用户
+func accessStatus(r *http.Request, key string, write bool) (int, string, error) {
+	// 规则对象本身不对外提供
+	if isAccessFile(key) && !write {
+		return http.StatusForbidden, "", nil
+	}
+	// JWT 按 -jwt-grant 授权，只能写入被授予写权限的前缀
+	if claims := requestClaims(r); claims != nil {
+		mode := claims.grant(key)
+		if mode == "write" || (mode == "read" && !write) {
+			return http.StatusOK, "", nil
+		}
+		if write {
+			return http.StatusForbidden, "", nil
+		}
+	}
+	if *accessFileName == "" {
+		return http.StatusOK, "", nil
+	}
+
+	rules, err := findAccessRules(context.Background(), key)
+	if err != nil {
+		return http.StatusInternalServerError, "", err
+	}
+	if rules == nil {
+		return http.StatusOK, "", nil
+	}
+
+	allowed := rules.read
+	if write {
+		allowed = rules.write
+	}
+	if allowed == nil || slices.Contains(allowed, "*") {
+		return http.StatusOK, "", nil
+	}
+	user := authenticatedUser(r)
+	if user == "" {
+		return http.StatusUnauthorized, "", nil
+	}
+	if !slices.Contains(allowed, user) {
+		return http.StatusForbidden, "", nil
+	}
+	return http.StatusOK, user, nil
+}
+
+// 列表、搜索等结果中的 key 是否可以展示给当前请求：不是规则对象、位于用户目录中且有读取权限
+func canRead(r *http.Request, key string) bool {
+	if isAccessFile(key) || !inHome(r, key) {
+		return false
+	}
+	status, _, _ := accessStatus(r, key, false)
+	return status == http.StatusOK
+}
+
+// 批量操作中的 key 是否可以写入：不是规则对象、位于用户目录中且有写入权限
+func canWrite(r *http.Request, key string) bool {
+	if isAccessFile(key) || !inHome(r, key) {
+		return false
+	}
+	status, _, _ := accessStatus(r, key, true)
+	return status == http.StatusOK
+}
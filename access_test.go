@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAccessRules(t *testing.T) {
+	enableWrites(t, credentialList{"u": "p", "v": "p"})
+	base := startTestGateway(t, map[string]string{
+		"pub.txt":     "pub",
+		"sec/s.txt":   "secret",
+		"sec/.access": "read: u\nwrite: u",
+	})
+
+	tests := []struct {
+		name   string
+		user   string
+		status int
+	}{
+		{"/sec/s.txt", "", http.StatusUnauthorized},
+		{"/sec/s.txt", "v", http.StatusForbidden},
+		{"/sec/s.txt", "u", http.StatusOK},
+		{"/sec/.access", "u", http.StatusForbidden},
+		{"/pub.txt", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if resp, _ := doRequest(t, http.MethodGet, base+tt.name, tt.user, nil); resp.StatusCode != tt.status {
+			t.Errorf("GET %s as %q: %s, want %d", tt.name, tt.user, resp.Status, tt.status)
+		}
+	}
+
+	// 列表与搜索中不出现无权读取的对象和规则对象
+	for _, target := range []string{"/?recursive=1&format=json", "/api/v1/list/?recursive=1", "/search?q=s&format=json", "/api/v1/search?q=s"} {
+		for user, visible := range map[string]bool{"": false, "v": false, "u": true} {
+			_, body := doRequest(t, http.MethodGet, base+target, user, nil)
+			if strings.Contains(body, "s.txt") != visible {
+				t.Errorf("GET %s as %q: sec/s.txt listed = %v, want %v", target, user, !visible, visible)
+			}
+			if strings.Contains(body, ".access") {
+				t.Errorf("GET %s as %q lists the access file", target, user)
+			}
+		}
+	}
+
+	// 写入同样受规则限制
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/sec/s.txt", "v", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("DELETE as v: %s", resp.Status)
+	}
+}
+
+func TestHomeDir(t *testing.T) {
+	setFlag(t, "home-dir", "users/{user}/")
+	enableWrites(t, credentialList{"u": "p", "v": "p"})
+	base := startTestGateway(t, map[string]string{
+		"users/u/a.txt": "a",
+		"users/v/b.txt": "b",
+	})
+
+	if resp, _ := doRequest(t, http.MethodGet, base+"/users/u/a.txt", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous GET: %s", resp.Status)
+	}
+	if _, body := doRequest(t, http.MethodGet, base+"/users/u/a.txt", "u", nil); body != "a" {
+		t.Errorf("GET own file: got %q", body)
+	}
+	if resp, _ := doRequest(t, http.MethodGet, base+"/users/v/b.txt", "u", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET another home: %s", resp.Status)
+	}
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/users/v/b.txt", "u", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("DELETE in another home: %s", resp.Status)
+	}
+
+	// 上级目录重定向到自己的目录
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	req, _ := http.NewRequest(http.MethodGet, base+"/users/", nil)
+	req.SetBasicAuth("u", "p")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "/users/u/" {
+		t.Errorf("GET /users/: %s to %q", resp.Status, resp.Header.Get("Location"))
+	}
+}
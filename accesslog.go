@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type accessLogSink struct {
+	format string
+	path   string
+	mu     sync.Mutex
+	out    io.Writer
+}
+
+// 可重复指定的 format:path 参数，path 为 - 时写到标准输出
+type accessLogSinks []*accessLogSink
+
+var accessLogs accessLogSinks
+
+func init() {
+	flag.Var(&accessLogs, "access-log", "The access log as format:path where format is combined, common or json and path - means stdout, can be repeated")
+	onPostResponse(writeAccessLog)
+}
+
+func (s *accessLogSinks) String() string {
+	var items []string
+	for _, sink := range *s {
+		items = append(items, sink.format+":"+sink.path)
+	}
+	return strings.Join(items, ",")
+}
+
+func (s *accessLogSinks) Set(value string) error {
+	format, path, ok := strings.Cut(value, ":")
+	if !ok || path == "" {
+		return fmt.Errorf("invalid value %q, expect format:path", value)
+	}
+	switch format {
+	case "combined", "common", "json":
+	default:
+		return fmt.Errorf("invalid access log format %q", format)
+	}
+	*s = append(*s, &accessLogSink{format: format, path: path})
+	return nil
+}
+
+func startAccessLog() {
+	for _, sink := range accessLogs {
+		if sink.path == "-" {
+			sink.out = os.Stdout
+			continue
+		}
+		file, err := os.OpenFile(sink.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			log.Fatal("访问日志打开失败: ", err)
+		}
+		sink.out = file
+	}
+}
+
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	IP        string    `json:"ip"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	Duration  float64   `json:"duration"` // 秒
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+}
+
+// 日志中只记录声明的用户名，不再校验密码
+func logUser(r *http.Request) string {
+	if name, _, ok := r.BasicAuth(); ok {
+		return name
+	}
+	if user := sessionUser(r); user != "" {
+		return user
+	}
+	return certUser(r)
+}
+
+func writeAccessLog(r *http.Request, resp *ResponseInfo) {
+	if len(accessLogs) == 0 {
+		return
+	}
+	entry := AccessEntry{
+		Time:      time.Now(),
+		IP:        clientIP(r),
+		User:      logUser(r),
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Proto:     r.Proto,
+		Status:    resp.Status,
+		Bytes:     resp.Bytes,
+		Duration:  resp.Duration.Seconds(),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+	}
+	for _, sink := range accessLogs {
+		line := entry.format(sink.format)
+		sink.mu.Lock()
+		if _, err := io.WriteString(sink.out, line); err != nil {
+			log.Printf("访问日志写入失败: %v", err)
+		}
+		sink.mu.Unlock()
+	}
+}
+
+// Apache 的 common/combined 格式，兼容 AWStats、GoAccess 等工具
+func (e AccessEntry) format(format string) string {
+	if format == "json" {
+		line, _ := json.Marshal(e)
+		return string(line) + "\n"
+	}
+	bytes := "-"
+	if e.Bytes > 0 {
+		bytes = strconv.FormatInt(e.Bytes, 10)
+	}
+	line := fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s",
+		e.IP, orDash(e.User), e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.Method, e.URI, e.Proto, e.Status, bytes)
+	if format == "combined" {
+		line += fmt.Sprintf(" %q %q", orDash(e.Referer), orDash(e.UserAgent))
+	}
+	return line + "\n"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/pprof"
+)
+
+var (
+	adminAddress = flag.String("admin-address", "", "The endpoint of admin service with pprof and metrics, disabled when empty")
+	adminUsers   = credentialList{}
+)
+
+func init() {
+	flag.Var(adminUsers, "admin-auth", "The user:password allowed to access admin service, can be repeated")
+}
+
+// 启动独立的管理端口，提供 pprof 性能分析与指标
+func startAdmin() {
+	if *adminAddress == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/maintenance", handleMaintenance)
+
+	go func() {
+		log.Println("管理服务启动在 " + *adminAddress + " 端口...")
+		log.Fatal(http.ListenAndServe(*adminAddress, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !basicAuth(w, r, adminUsers) {
+				return
+			}
+			mux.ServeHTTP(w, r)
+		})))
+	}()
+}
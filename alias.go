@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// 公开路径到存储桶前缀的静态别名
+var aliases prefixMap
+
+func init() {
+	flag.Var(&aliases, "alias", "The public path alias of a key prefix as path=prefix, can be repeated")
+}
+
+// 判断 key 是否位于 prefix 之下（按路径分段匹配）
+func underPrefix(key, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, strings.TrimSuffix(prefix, "/"))
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", false
+	}
+	return rest, true
+}
+
+// 将请求路径映射为对象 key
+func aliasKey(requestKey string) string {
+	var found prefixValue
+	var foundRest string
+	ok := false
+	for _, alias := range aliases {
+		if rest, match := underPrefix(requestKey, alias.Prefix); match && (!ok || len(alias.Prefix) > len(found.Prefix)) {
+			found, foundRest, ok = alias, rest, true
+		}
+	}
+	if !ok {
+		return requestKey
+	}
+	return strings.TrimSuffix(found.Value, "/") + foundRest
+}
+
+// 对象 key 对外展示的 URL 路径
+func keyURL(key string) string {
+	var found prefixValue
+	var foundRest string
+	ok := false
+	for _, alias := range aliases {
+		if rest, match := underPrefix(key, alias.Value); match && (!ok || len(alias.Value) > len(found.Value)) {
+			found, foundRest, ok = alias, rest, true
+		}
+	}
+	if !ok {
+		return "/" + key
+	}
+	return "/" + strings.TrimSuffix(found.Prefix, "/") + foundRest
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// OpenAPI 描述文档
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+type ObjectStat struct {
+	Key          string            `json:"key"`
+	Size         int64             `json:"size"`
+	ETag         string            `json:"etag"`
+	ContentType  string            `json:"contentType"`
+	LastModified time.Time         `json:"lastModified"`
+	StorageClass string            `json:"storageClass,omitempty"`
+	VersionID    string            `json:"versionId,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"` // 用户自定义元数据，不含 X-Amz-Meta- 前缀
+	Lock         *ObjectLock       `json:"lock,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+}
+
+type PrefixStats struct {
+	Prefix    string `json:"prefix"`
+	Files     int    `json:"files"`
+	TotalSize int64  `json:"totalSize"`
+}
+
+// 前缀下各级子树的文件数量和总大小
+type TreeSize struct {
+	Prefix    string      `json:"prefix"`
+	Files     int64       `json:"files"`
+	TotalSize int64       `json:"totalSize"`
+	Children  []*TreeSize `json:"children,omitempty"`
+}
+
+type DownloadCount struct {
+	Key       string `json:"key"`
+	Downloads int64  `json:"downloads"`
+}
+
+type APIError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("响应写入失败: %v", err)
+	}
+}
+
+// /api/v1/ 下的 JSON 接口
+func handleAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, APIError{"method not allowed"})
+		return
+	}
+
+	route, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	// 与页面相同，路径中的别名解析为实际 key
+	key = aliasKey(key)
+	if (route == "list" || route == "stat" || route == "downloads") && !checkAccess(w, r, key, false) {
+		return
+	}
+	// 按前缀查询的接口同样受访问规则与用户目录限制
+	if route == "search" || route == "stats" || route == "du" {
+		prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		if !checkAccess(w, r, prefix, false) {
+			return
+		}
+	}
+	switch route {
+	case "openapi.json":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	case "list":
+		apiList(w, r, key)
+	case "stat":
+		apiStat(w, r, key)
+	case "search":
+		apiSearch(w, r)
+	case "stats":
+		apiStats(w, r)
+	case "du":
+		apiDu(w, r)
+	case "downloads":
+		apiDownloads(w, key)
+	case "quota":
+		apiQuota(w, r)
+	default:
+		writeJSON(w, http.StatusNotFound, APIError{"not found"})
+	}
+}
+
+func apiList(w http.ResponseWriter, r *http.Request, prefix string) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !listingAllowed(prefix) {
+		writeJSON(w, http.StatusForbidden, APIError{"listing disabled"})
+		return
+	}
+	visible := func(key string) bool { return canRead(r, key) }
+	listing, err := listDirectory(context.Background(), prefix, r.URL.Query().Get("recursive") == "1", visible)
+	if err != nil {
+		log.Printf("目录列表错误: %v", err)
+		writeJSON(w, http.StatusBadGateway, APIError{"backend error"})
+		return
+	}
+	if listing == nil {
+		writeJSON(w, http.StatusNotFound, APIError{"not found"})
+		return
+	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		listing.filterTag(context.Background(), tag)
+	} else if r.URL.Query().Get("tags") == "1" {
+		fillTags(context.Background(), listing.Entries)
+	}
+	writeJSON(w, http.StatusOK, listing)
+}
+
+func apiStat(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" || findMount(key) == nil {
+		writeJSON(w, http.StatusNotFound, APIError{"not found"})
+		return
+	}
+	m, objectKey := resolveKey(key)
+	opts := minio.StatObjectOptions{VersionID: r.URL.Query().Get("versionId")}
+	objInfo, err := statObject(context.Background(), m, objectKey, opts)
+	if err != nil {
+		if code := minio.ToErrorResponse(err).Code; code == "NoSuchKey" || code == "NoSuchVersion" {
+			writeJSON(w, http.StatusNotFound, APIError{"not found"})
+			return
+		}
+		log.Printf("文件检查失败: %v", err)
+		writeJSON(w, http.StatusBadGateway, APIError{"backend error"})
+		return
+	}
+	if !fileAllowed(key, objectContentType(key, objInfo.ContentType)) {
+		writeJSON(w, http.StatusForbidden, APIError{"forbidden"})
+		return
+	}
+	tags, err := objectTags(context.Background(), key)
+	if err != nil {
+		log.Printf("标签获取失败: %v", err)
+	}
+	writeJSON(w, http.StatusOK, ObjectStat{
+		Key:          key,
+		Size:         objInfo.Size,
+		ETag:         objInfo.ETag,
+		ContentType:  objectContentType(key, objInfo.ContentType),
+		LastModified: objInfo.LastModified,
+		StorageClass: objInfo.StorageClass,
+		VersionID:    objInfo.VersionID,
+		Metadata:     objInfo.UserMetadata,
+		Lock:         objectLock(objInfo),
+		Tags:         tags,
+	})
+}
+
+func apiSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+	tag := r.URL.Query().Get("tag")
+	if !listingAllowed(prefix) {
+		writeJSON(w, http.StatusForbidden, APIError{"listing disabled"})
+		return
+	}
+	visible := searchVisible(r, tag)
+	results, truncated, err := searchObjects(r.Context(), prefix, query, r.URL.Query().Get("mode"), *searchLimit, visible)
+	if err != nil {
+		log.Printf("目录列表错误: %v", err)
+		writeJSON(w, http.StatusBadGateway, APIError{"backend error"})
+		return
+	}
+	if tag != "" {
+		fillTags(r.Context(), results)
+	}
+	writeJSON(w, http.StatusOK, SearchResult{query, keyURL(prefix), results, truncated, tag})
+}
+
+func apiDownloads(w http.ResponseWriter, key string) {
+	if !countersEnabled() || key == "" {
+		writeJSON(w, http.StatusNotFound, APIError{"not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, DownloadCount{key, downloadCount(key)})
+}
+
+// 当前认证用户的配额与用量
+func apiQuota(w http.ResponseWriter, r *http.Request) {
+	if !quotasEnabled() {
+		writeJSON(w, http.StatusNotFound, APIError{"not found"})
+		return
+	}
+	user := authenticatedUser(r)
+	if user == "" {
+		logAuthFailure(r)
+		w.Header().Set("WWW-Authenticate", `Basic realm="bucket2http"`)
+		writeJSON(w, http.StatusUnauthorized, APIError{"unauthorized"})
+		return
+	}
+	writeJSON(w, http.StatusOK, quotaInfo(user))
+}
+
+// 统计前缀下的文件数量和总大小
+func apiStats(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !listingAllowed(prefix) {
+		writeJSON(w, http.StatusForbidden, APIError{"listing disabled"})
+		return
+	}
+	stats := PrefixStats{Prefix: keyURL(prefix)}
+	for obj := range listObjects(context.Background(), minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			log.Printf("目录列表错误: %v", obj.Err)
+			writeJSON(w, http.StatusBadGateway, APIError{"backend error"})
+			return
+		}
+		// 与 du 相同，禁止列出的子树整体不计入
+		if strings.HasSuffix(obj.Key, "/") || !subtreeListable(prefix, obj.Key) || !canRead(r, obj.Key) {
+			continue
+		}
+		stats.Files++
+		stats.TotalSize += obj.Size
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// 按子树汇总文件数量和大小，depth 为展开的目录层数
+func apiDu(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	depth := 1
+	if value := r.URL.Query().Get("depth"); value != "" {
+		var err error
+		if depth, err = strconv.Atoi(value); err != nil || depth < 0 {
+			writeJSON(w, http.StatusBadRequest, APIError{"invalid depth"})
+			return
+		}
+	}
+	if !listingAllowed(prefix) {
+		writeJSON(w, http.StatusForbidden, APIError{"listing disabled"})
+		return
+	}
+
+	root := &TreeSize{Prefix: keyURL(prefix)}
+	nodes := map[string]*TreeSize{prefix: root}
+	for obj := range listObjects(r.Context(), minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			log.Printf("目录列表错误: %v", obj.Err)
+			writeJSON(w, http.StatusBadGateway, APIError{"backend error"})
+			return
+		}
+		// 禁止列出的子树不出现在结果中，也不计入上级的统计
+		if strings.HasSuffix(obj.Key, "/") || !subtreeListable(prefix, obj.Key) || !canRead(r, obj.Key) {
+			continue
+		}
+		// 计入前缀本身以及 depth 层以内的各级上级目录
+		node, dir := root, prefix
+		node.Files++
+		node.TotalSize += obj.Size
+		parts := strings.Split(strings.TrimPrefix(obj.Key, prefix), "/")
+		for _, part := range parts[:min(depth, len(parts)-1)] {
+			dir += part + "/"
+			child := nodes[dir]
+			if child == nil {
+				child = &TreeSize{Prefix: keyURL(dir)}
+				nodes[dir] = child
+				node.Children = append(node.Children, child)
+			}
+			child.Files++
+			child.TotalSize += obj.Size
+			node = child
+		}
+	}
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].Prefix < node.Children[j].Prefix })
+	}
+	writeJSON(w, http.StatusOK, root)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 归档文件提示页面模板
+const archivedTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Archived: {{.Path}}</title>
+    {{template "style"}}
+</head>
+<body>
+    <h1>{{.Path}} is archived</h1>
+    <p>This file is stored in an archive storage class and must be restored before it can be downloaded.</p>
+    {{if .Status.Restoring}}
+    <p>Restore in progress, please check back later.</p>
+    {{else if .Writable}}
+    <button onclick="thaw()">Restore</button>
+    <script>
+        async function thaw() {
+            const resp = await fetch('?thaw', {method: 'POST', headers: {'X-CSRF-Token': {{.CSRF}}}});
+            if (!resp.ok) {
+                alert('Restore: ' + resp.status);
+            }
+            location.reload();
+        }
+    </script>
+    {{end}}
+</body>
+</html>`
+
+var (
+	archivedMode  = flag.String("archived", "mark", "How archived objects are handled: hide, mark or deny")
+	archivedTmpl  = newPageTemplate("archived", archivedTemplate)
+	archivedModes prefixMap
+	thawDays      = flag.Int("thaw-days", 7, "The default number of days a restored archive copy is kept")
+)
+
+// 归档文件的解冻状态
+type ThawStatus struct {
+	Key           string     `json:"key"`
+	StorageClass  string     `json:"storageClass"`
+	Archived      bool       `json:"archived"`
+	Restoring     bool       `json:"restoring"`
+	RestoredUntil *time.Time `json:"restoredUntil,omitempty"`
+}
+
+func init() {
+	flag.Var(&archivedModes, "archived-prefix", "The prefix=mode handling of archived objects under a prefix, can be repeated")
+}
+
+// 需要解冻后才能读取的存储类型
+func isArchived(storageClass string) bool {
+	switch storageClass {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return true
+	}
+	return false
+}
+
+// 已解冻且未过期的归档文件可以直接下载，不再按归档处理
+func restored(ctx context.Context, key string) bool {
+	m, objectKey := resolveKey(key)
+	objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{})
+	return err == nil && objInfo.Restore != nil && !objInfo.Restore.OngoingRestore && objInfo.Restore.ExpiryTime.After(time.Now())
+}
+
+// 校验归档文件的处理方式
+func startArchive() {
+	modes := []string{*archivedMode}
+	for _, item := range archivedModes {
+		modes = append(modes, item.Value)
+	}
+	for _, mode := range modes {
+		switch mode {
+		case "hide", "mark", "deny":
+		default:
+			log.Fatalf("归档处理方式无效: %s", mode)
+		}
+	}
+}
+
+// 归档文件的处理方式：hide 隐藏，mark 在列表中标记，deny 仅拒绝下载
+func archiveMode(key string) string {
+	if item, ok := archivedModes.lookup(key); ok {
+		return item.Value
+	}
+	return *archivedMode
+}
+
+// 归档文件无法直接下载，隐藏模式下返回 404，否则返回 403 说明页面
+func handleArchived(w http.ResponseWriter, r *http.Request, key string) {
+	if archiveMode(key) == "hide" {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	status, err := thawStatus(context.Background(), key)
+	if err != nil {
+		log.Printf("文件检查失败: %v", err)
+	}
+	canThaw := *writable && !*readOnly
+	csrf := ""
+	if canThaw {
+		csrf = csrfToken(w, r)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusForbidden)
+	err = archivedTmpl.Execute(w, struct {
+		Path     string
+		Status   ThawStatus
+		Writable bool
+		CSRF     string
+	}{keyURL(key), status, canThaw, csrf})
+	if err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
+
+func thawStatus(ctx context.Context, key string) (ThawStatus, error) {
+	m, objectKey := resolveKey(key)
+	objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return ThawStatus{Key: key}, err
+	}
+	status := ThawStatus{
+		Key:          key,
+		StorageClass: objInfo.StorageClass,
+		Archived:     isArchived(objInfo.StorageClass),
+	}
+	if objInfo.Restore != nil {
+		status.Restoring = objInfo.Restore.OngoingRestore
+		if !objInfo.Restore.ExpiryTime.IsZero() {
+			status.RestoredUntil = &objInfo.Restore.ExpiryTime
+		}
+	}
+	return status, nil
+}
+
+// GET ?thaw 查询解冻状态
+func handleThawStatus(w http.ResponseWriter, key string) {
+	status, err := thawStatus(context.Background(), key)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			writeJSON(w, http.StatusNotFound, APIError{"not found"})
+			return
+		}
+		log.Printf("文件检查失败: %v", err)
+		writeJSON(w, http.StatusBadGateway, APIError{"backend error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// POST ?thaw&days=N&tier=Standard 发起解冻
+func handleThaw(w http.ResponseWriter, r *http.Request, key string) {
+	days := *thawDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "400 Bad Request", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	req := minio.RestoreRequest{}
+	req.SetDays(days)
+	if tier := r.URL.Query().Get("tier"); tier != "" {
+		req.SetGlacierJobParameters(minio.GlacierJobParameters{Tier: minio.TierType(tier)})
+	}
+	ctx := context.Background()
+	m, objectKey := resolveKey(key)
+	if err := m.client().RestoreObject(ctx, m.Bucket, objectKey, "", req); err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey":
+			http.Error(w, "404 Not Found", http.StatusNotFound)
+		case "RestoreAlreadyInProgress", "InvalidObjectState":
+			http.Error(w, "409 Conflict", http.StatusConflict)
+		default:
+			log.Printf("文件解冻失败: %v", err)
+			http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		}
+		return
+	}
+
+	status, err := thawStatus(ctx, key)
+	if err != nil {
+		log.Printf("文件检查失败: %v", err)
+	}
+	writeJSON(w, http.StatusAccepted, status)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	auditLog      = flag.String("audit-log", "", "The append-only audit log of write operations, a file path or bucket:prefix/ to upload batches as objects")
+	auditInterval = flag.Duration("audit-interval", time.Minute, "How often buffered audit entries are uploaded when -audit-log is bucket:prefix/")
+)
+
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user,omitempty"`
+	IP          string    `json:"ip"`
+	Method      string    `json:"method"`
+	Key         string    `json:"key"`
+	Destination string    `json:"destination,omitempty"`
+	Size        int64     `json:"size"`
+	Status      int       `json:"status"`
+}
+
+var audit struct {
+	sync.Mutex
+	file   *os.File
+	prefix string
+	buf    bytes.Buffer
+}
+
+func startAudit() {
+	if *auditLog == "" {
+		return
+	}
+	if prefix, ok := strings.CutPrefix(*auditLog, "bucket:"); ok {
+		audit.prefix = strings.TrimPrefix(prefix, "/")
+		go func() {
+			for range time.Tick(*auditInterval) {
+				flushAudit()
+			}
+		}()
+		return
+	}
+	file, err := os.OpenFile(*auditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		log.Fatal("审计日志打开失败: ", err)
+	}
+	audit.file = file
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return realIP(r, host)
+}
+
+func writeAudit(entry AuditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("审计日志写入失败: %v", err)
+		return
+	}
+	line = append(line, '\n')
+
+	audit.Lock()
+	defer audit.Unlock()
+	if audit.file != nil {
+		if _, err := audit.file.Write(line); err != nil {
+			log.Printf("审计日志写入失败: %v", err)
+		}
+		return
+	}
+	audit.buf.Write(line)
+}
+
+// 将缓冲的审计记录作为一个新对象上传，对象一经写入不再修改
+func flushAudit() {
+	audit.Lock()
+	data := bytes.Clone(audit.buf.Bytes())
+	audit.buf.Reset()
+	audit.Unlock()
+	if len(data) == 0 {
+		return
+	}
+
+	key := audit.prefix + time.Now().UTC().Format("20060102T150405.000000000Z") + ".jsonl"
+	m, objectKey := resolveKey(key)
+	_, err := m.client().PutObject(context.Background(), m.Bucket, objectKey, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/x-ndjson",
+	})
+	if err != nil {
+		log.Printf("审计日志上传失败: %v", err)
+		// 放回缓冲区，下次重试
+		audit.Lock()
+		audit.buf.Write(data)
+		audit.Unlock()
+	}
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 执行写操作并记录审计日志
+func auditWrite(w http.ResponseWriter, r *http.Request, key string, next func(http.ResponseWriter, *http.Request)) {
+	if *auditLog == "" {
+		next(w, r)
+		return
+	}
+	body := &countingReader{ReadCloser: r.Body}
+	r.Body = body
+	rec := &responseRecorder{ResponseWriter: w}
+	next(rec, r)
+
+	dest, _ := destinationKey(r)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	writeAudit(AuditEntry{
+		Time:        time.Now(),
+		User:        authenticatedUser(r),
+		IP:          clientIP(r),
+		Method:      r.Method,
+		Key:         key,
+		Destination: dest,
+		Size:        body.n,
+		Status:      rec.status,
+	})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 用户名 -> 密码
+type credentialList map[string]string
+
+func (c credentialList) String() string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (c credentialList) Set(value string) error {
+	name, password, ok := strings.Cut(value, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid credential %q, expect user:password", value)
+	}
+	c[name] = password
+	return nil
+}
+
+var writeUsers = credentialList{}
+
+func init() {
+	flag.Var(writeUsers, "auth", "The user:password allowed to write, can be repeated")
+}
+
+// 校验值与密码是否一致
+func (c credentialList) verify(name, password string) bool {
+	expected, found := c[name]
+	return found && subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+}
+
+type userContextKey struct{}
+
+// 请求的已认证用户，首次使用时解析
+type requestUser struct {
+	once sync.Once
+	name string
+}
+
+// 每个请求只认证一次，列表中逐个对象判断权限时不再重复校验密码
+func withUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, &requestUser{})))
+	})
+}
+
+// 请求对应的已认证用户，未认证时返回空
+func authenticatedUser(r *http.Request) string {
+	if u, ok := r.Context().Value(userContextKey{}).(*requestUser); ok {
+		u.once.Do(func() { u.name = resolveUser(r) })
+		return u.name
+	}
+	return resolveUser(r)
+}
+
+func resolveUser(r *http.Request) string {
+	if user := sessionUser(r); user != "" {
+		return user
+	}
+	if claims := requestClaims(r); claims != nil {
+		return claims.user()
+	}
+	// 客户端证书对应的用户无需密码
+	if user := certUser(r); user != "" {
+		if _, ok := writeUsers[user]; ok || htpasswd.has(user) {
+			return user
+		}
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		if writeUsers.verify(name, password) || htpasswd.verify(name, password) {
+			return name
+		}
+	}
+	return ""
+}
+
+// 校验写操作的认证，未配置用户时不做限制
+func checkAuth(w http.ResponseWriter, r *http.Request) bool {
+	if len(writeUsers) == 0 && *htpasswdPath == "" && *jwksURL == "" {
+		return true
+	}
+	if user := authenticatedUser(r); user != "" {
+		issueSession(w, r, user)
+		return true
+	}
+	requireAuth(w, r)
+	return false
+}
+
+// 要求已认证用户，未配置任何用户时也不例外
+func checkUser(w http.ResponseWriter, r *http.Request) bool {
+	if authenticatedUser(r) != "" {
+		return true
+	}
+	requireAuth(w, r)
+	return false
+}
+
+// 移动与复制必须由已认证用户发起
+func checkTransferAuth(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "MOVE" && r.Method != "COPY" {
+		return true
+	}
+	return checkUser(w, r)
+}
+
+func basicAuth(w http.ResponseWriter, r *http.Request, users credentialList) bool {
+	if len(users) == 0 {
+		return true
+	}
+	if name, password, ok := r.BasicAuth(); ok && users.verify(name, password) {
+		return true
+	}
+	requireAuth(w, r)
+	return false
+}
+
+func requireAuth(w http.ResponseWriter, r *http.Request) {
+	logAuthFailure(r)
+	w.Header().Set("WWW-Authenticate", `Basic realm="bucket2http"`)
+	http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"path"
+	"strings"
+)
+
+var (
+	autoindex  = flag.Bool("autoindex", true, "List the contents of directories, directory URLs return 403 when disabled")
+	hiddenDirs stringList
+)
+
+func init() {
+	flag.Var(&hiddenDirs, "no-listing", "The glob of directories whose listing is disabled while files are still served, such as /private/**, can be repeated")
+}
+
+// 判断是否允许列出 prefix 下的内容
+func listingAllowed(prefix string) bool {
+	if !*autoindex {
+		return false
+	}
+	dir := strings.Trim(prefix, "/")
+	for _, pattern := range hiddenDirs {
+		if matchDirGlob(strings.Trim(pattern, "/"), dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// 文件所在目录禁止列出时，递归列表与搜索结果中也不出现该文件
+func keyListable(key string) bool {
+	dir := path.Dir(strings.TrimSuffix(key, "/"))
+	if dir == "." {
+		dir = ""
+	}
+	return listingAllowed(dir)
+}
+
+// prefix 与 key 之间的各级目录都允许列出，用于按子树汇总的统计
+func subtreeListable(prefix, key string) bool {
+	dir := prefix
+	parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir += part + "/"
+		if !listingAllowed(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// 按路径分段匹配，* 匹配一段，** 匹配任意多段（包括零段）
+func matchDirGlob(pattern, dir string) bool {
+	var patterns, names []string
+	if pattern != "" {
+		patterns = strings.Split(pattern, "/")
+	}
+	if dir != "" {
+		names = strings.Split(dir, "/")
+	}
+	return matchSegments(patterns, names)
+}
+
+func matchSegments(patterns, names []string) bool {
+	if len(patterns) == 0 {
+		return len(names) == 0
+	}
+	if patterns[0] == "**" {
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(patterns[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(names) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(patterns[0], names[0]); !ok {
+		return false
+	}
+	return matchSegments(patterns[1:], names[1:])
+}
+
+// 禁止列目录时，存在的目录返回 403，不存在的返回 false 交由调用方按 404 处理
+func denyListing(w http.ResponseWriter, prefix string) bool {
+	if prefix != "" && !dirExists(prefix) {
+		return false
+	}
+	http.Error(w, "403 Forbidden", http.StatusForbidden)
+	return true
+}
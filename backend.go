@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	waitForBackend = flag.Bool("wait-for-backend", false, "Retry connecting to oss at startup instead of exiting, /readyz returns 503 until connected")
+	backendReady   atomic.Bool
+)
+
+// 建立挂载表并加载保存在后端的状态
+func initBackend(ctx context.Context) error {
+	if err := setupMounts(ctx); err != nil {
+		return err
+	}
+	if *waitForBackend {
+		for _, m := range mounts {
+			exists, err := m.client().BucketExists(ctx, m.Bucket)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("bucket %s does not exist", m.Bucket)
+			}
+		}
+	}
+	if err := startCounters(); err != nil {
+		return err
+	}
+	if err := startRevocations(); err != nil {
+		return err
+	}
+	return startQuotas()
+}
+
+func startBackend() {
+	if !*waitForBackend {
+		if err := initBackend(context.Background()); err != nil {
+			log.Fatal("后端初始化失败: ", err)
+		}
+		backendReady.Store(true)
+		return
+	}
+
+	// 后端就绪前持续重试，间隔逐步加长
+	go func() {
+		delay := time.Second
+		for {
+			err := initBackend(context.Background())
+			if err == nil {
+				backendReady.Store(true)
+				log.Println("后端连接成功")
+				return
+			}
+			log.Printf("后端连接失败，%v 后重试: %v", delay, err)
+			time.Sleep(delay)
+			delay = min(delay*2, 30*time.Second)
+		}
+	}()
+}
+
+// 后端就绪前数据请求一律返回 503
+func withReady(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !backendReady.Load() {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "503 Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok\n"))
+}
+
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if !backendReady.Load() || canaryFailing.Load() {
+		http.Error(w, "503 Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok\n"))
+}
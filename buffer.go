@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"sync"
+)
+
+var (
+	copyBufferSize = flag.Int("copy-buffer-size", 256<<10, "The buffer size in bytes used to stream downloads")
+
+	copyBufPool = sync.Pool{
+		New: func() any {
+			buf := make([]byte, *copyBufferSize)
+			return &buf
+		},
+	}
+)
+
+// 使用池化缓冲区复制数据，减少大量并发下载时的内存分配
+func copyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	buf := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(buf)
+	// 隐藏 ReaderFrom/WriterTo，确保使用池化缓冲区
+	return io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, *buf)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	cacheDir           = flag.String("cache-dir", "", "The directory objects are cached in on local disk, disabled when empty")
+	cacheSize          = flag.Int64("cache-size", 10<<30, "The maximum total size in bytes of the disk cache, 0 means unlimited")
+	cacheMaxObjectSize = flag.Int64("cache-max-object-size", 256<<20, "The size in bytes above which objects are not cached")
+	cacheTTL           = flag.Duration("cache-ttl", time.Minute, "How long a cached object is served before its ETag is checked against oss again")
+	prewarmInterval    = flag.Duration("prewarm-interval", 0, "How often the -prewarm prefixes are fetched into the cache again, 0 only prewarms at startup")
+	prewarmPrefixes    stringList
+
+	cacheUsed  atomic.Int64
+	cacheEvict sync.Mutex
+)
+
+// 缓存文件名 -> 对象 key，用于按前缀失效
+var cacheIndex struct {
+	sync.Mutex
+	keys map[string]string
+}
+
+// 正在写入缓存的 key，同一 key 的并发未命中只由第一个请求写入
+var cacheFilling struct {
+	sync.Mutex
+	keys map[string]bool
+}
+
+func init() {
+	flag.Var(&prewarmPrefixes, "prewarm", "The prefix whose objects are fetched into the disk cache at startup, can be repeated")
+}
+
+// 缓存文件旁的元数据
+type cacheMeta struct {
+	Key          string    `json:"key"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"contentType"`
+	LastModified time.Time `json:"lastModified"`
+	Checked      time.Time `json:"checked"` // 最近一次与后端确认的时间
+}
+
+func (c cacheMeta) objectInfo() minio.ObjectInfo {
+	return minio.ObjectInfo{Key: c.Key, ETag: c.ETag, Size: c.Size, ContentType: c.ContentType, LastModified: c.LastModified}
+}
+
+func startCache() {
+	if *cacheDir == "" {
+		return
+	}
+	loadCacheKey()
+	if err := os.MkdirAll(*cacheDir, 0o700); err != nil {
+		log.Fatal("缓存目录创建失败: ", err)
+	}
+	// 统计已有缓存并建立索引，清理上次未完成的临时文件
+	var used int64
+	cacheIndex.keys = map[string]string{}
+	cacheFilling.keys = map[string]bool{}
+	filepath.WalkDir(*cacheDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch {
+		case strings.HasSuffix(name, ".tmp"):
+			os.Remove(name)
+		case !strings.HasSuffix(name, ".json"):
+			if info, err := d.Info(); err == nil {
+				used += info.Size()
+			}
+			if meta, err := readCacheMeta(name); err == nil {
+				cacheIndex.keys[name] = meta.Key
+			}
+		}
+		return nil
+	})
+	cacheUsed.Store(used)
+	startPrewarm()
+}
+
+func cachePath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(*cacheDir, hex.EncodeToString(sum[:]))
+}
+
+func readCacheMeta(name string) (*cacheMeta, error) {
+	data, err := os.ReadFile(name + ".json")
+	if err != nil {
+		return nil, err
+	}
+	if data, err = openCacheBlob(name+".json", data); err != nil {
+		return nil, err
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func writeCacheMeta(name string, meta *cacheMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if data, err = sealCacheBlob(name+".json", data); err != nil {
+		return err
+	}
+	return writeFileAtomic(name+".json", bytes.NewReader(data))
+}
+
+// 只获取文件信息与开头的内容，用于不发送内容的 HEAD 请求，不启动并行获取、预读与缓存填充
+func openObjectHead(ctx context.Context, key, versionID string) (io.ReadCloser, minio.ObjectInfo, error) {
+	m, objectKey := resolveKey(key)
+	object, objInfo, _, err := getObject(ctx, m, objectKey, minio.GetObjectOptions{VersionID: versionID})
+	return object, objInfo, err
+}
+
+// 获取对象内容，启用磁盘缓存时优先从缓存读取，未命中时边传输边写入缓存
+func openObject(ctx context.Context, key, versionID string) (io.ReadCloser, minio.ObjectInfo, error) {
+	m, objectKey := resolveKey(key)
+	useCache := *cacheDir != "" && versionID == ""
+	if useCache {
+		if object, objInfo, ok := openCached(ctx, m, key, objectKey); ok {
+			return object, objInfo, nil
+		}
+	}
+	object, objInfo, _, err := getObject(ctx, m, objectKey, minio.GetObjectOptions{VersionID: versionID})
+	if err != nil {
+		return nil, objInfo, err
+	}
+	if parallel := fetchParallel(ctx, m, objectKey, versionID, objInfo, object); parallel != object {
+		object = parallel
+	} else {
+		object = readAhead(object, objInfo.Size)
+	}
+	if !useCache || objInfo.Size > *cacheMaxObjectSize {
+		return object, objInfo, nil
+	}
+	return newCacheWriter(key, objInfo, object), objInfo, nil
+}
+
+// 读取缓存，超过 cache-ttl 时先比对后端 ETag
+func openCached(ctx context.Context, m *Mount, key, objectKey string) (io.ReadCloser, minio.ObjectInfo, bool) {
+	name := cachePath(key)
+	meta, err := readCacheMeta(name)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, false
+	}
+	if time.Since(meta.Checked) > *cacheTTL {
+		objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{})
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				dropCached(key)
+			}
+			return nil, minio.ObjectInfo{}, false
+		}
+		if objInfo.ETag != meta.ETag {
+			dropCached(key)
+			return nil, minio.ObjectInfo{}, false
+		}
+		meta.Checked = time.Now()
+		writeCacheMeta(name, meta)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, false
+	}
+	if info, err := file.Stat(); err != nil || info.Size() != cacheFileSize(meta.Size) {
+		file.Close()
+		return nil, minio.ObjectInfo{}, false
+	}
+	reader, err := newCacheDecrypter(file, name)
+	if err != nil {
+		file.Close()
+		return nil, minio.ObjectInfo{}, false
+	}
+	// 以修改时间记录最近访问，淘汰时优先删除最久未访问的文件
+	now := time.Now()
+	os.Chtimes(name, now, now)
+	return &cachedReader{Reader: reader, Closer: file, name: name}, meta.objectInfo(), true
+}
+
+func dropCached(key string) {
+	if *cacheDir == "" {
+		return
+	}
+	removeCacheFile(cachePath(key))
+}
+
+// 删除前缀下的全部缓存，目录删除、移动或复制后调用
+func dropCachedPrefix(prefix string) {
+	if *cacheDir == "" {
+		return
+	}
+	var names []string
+	cacheIndex.Lock()
+	for name, key := range cacheIndex.keys {
+		if strings.HasPrefix(key, prefix) {
+			names = append(names, name)
+		}
+	}
+	cacheIndex.Unlock()
+	for _, name := range names {
+		removeCacheFile(name)
+	}
+}
+
+func removeCacheFile(name string) {
+	if info, err := os.Stat(name); err == nil && os.Remove(name) == nil {
+		cacheUsed.Add(-info.Size())
+	}
+	os.Remove(name + ".json")
+	cacheIndex.Lock()
+	delete(cacheIndex.keys, name)
+	cacheIndex.Unlock()
+}
+
+// 占用 key 的缓存写入，已有请求在写入时返回 false
+func claimCacheFill(key string) bool {
+	cacheFilling.Lock()
+	defer cacheFilling.Unlock()
+	if cacheFilling.keys[key] {
+		return false
+	}
+	cacheFilling.keys[key] = true
+	return true
+}
+
+func releaseCacheFill(key string) {
+	cacheFilling.Lock()
+	delete(cacheFilling.keys, key)
+	cacheFilling.Unlock()
+}
+
+// 缓存文件损坏时删除，下次请求重新从后端获取
+type cachedReader struct {
+	io.Reader
+	io.Closer
+	name string
+}
+
+func (c *cachedReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	if err == errCacheCorrupted {
+		log.Printf("缓存文件损坏，已删除: %s", c.name)
+		removeCacheFile(c.name)
+	}
+	return n, err
+}
+
+// 读取对象的同时写入临时文件，完整读取后才放入缓存
+type cacheWriter struct {
+	io.ReadCloser
+	key     string
+	objInfo minio.ObjectInfo
+	tmp     *os.File
+	out     io.WriteCloser // 配置了密钥时加密写入 tmp
+	written int64
+	failed  bool
+}
+
+// 同一 key 已有请求在写入缓存时直接返回 object，不重复写入
+func newCacheWriter(key string, objInfo minio.ObjectInfo, object io.ReadCloser) io.ReadCloser {
+	if !claimCacheFill(key) {
+		return object
+	}
+	tmp, err := os.CreateTemp(*cacheDir, "*.tmp")
+	if err != nil {
+		log.Printf("缓存文件创建失败: %v", err)
+		releaseCacheFill(key)
+		return object
+	}
+	out, err := newCacheEncrypter(tmp, cachePath(key))
+	if err != nil {
+		log.Printf("缓存文件创建失败: %v", err)
+		tmp.Close()
+		os.Remove(tmp.Name())
+		releaseCacheFill(key)
+		return object
+	}
+	return &cacheWriter{ReadCloser: object, key: key, objInfo: objInfo, tmp: tmp, out: out}
+}
+
+func (c *cacheWriter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if n > 0 && !c.failed {
+		if _, werr := c.out.Write(p[:n]); werr != nil {
+			c.failed = true
+		}
+		c.written += int64(n)
+	}
+	return n, err
+}
+
+func (c *cacheWriter) Close() error {
+	defer releaseCacheFill(c.key)
+	err := c.ReadCloser.Close()
+	if cerr := c.out.Close(); cerr != nil {
+		c.failed = true
+	}
+	c.tmp.Close()
+	if c.failed || c.written != c.objInfo.Size {
+		os.Remove(c.tmp.Name())
+		return err
+	}
+	// 替换旧版本的缓存文件时扣除其占用
+	name := cachePath(c.key)
+	var replaced int64
+	if info, err := os.Stat(name); err == nil {
+		replaced = info.Size()
+	}
+	if rerr := os.Rename(c.tmp.Name(), name); rerr != nil {
+		log.Printf("缓存文件写入失败: %v", rerr)
+		os.Remove(c.tmp.Name())
+		return err
+	}
+	meta := &cacheMeta{
+		Key:          c.key,
+		ETag:         c.objInfo.ETag,
+		Size:         c.objInfo.Size,
+		ContentType:  c.objInfo.ContentType,
+		LastModified: c.objInfo.LastModified,
+		Checked:      time.Now(),
+	}
+	if merr := writeCacheMeta(name, meta); merr != nil {
+		log.Printf("缓存文件写入失败: %v", merr)
+	}
+	cacheIndex.Lock()
+	cacheIndex.keys[name] = c.key
+	cacheIndex.Unlock()
+	if cacheUsed.Add(cacheFileSize(c.written)-replaced) > *cacheSize && *cacheSize > 0 {
+		go evictCache()
+	}
+	return err
+}
+
+// 按最近访问时间淘汰缓存，直到占用降到上限的 90%
+func evictCache() {
+	if !cacheEvict.TryLock() {
+		return
+	}
+	defer cacheEvict.Unlock()
+	type cached struct {
+		name    string
+		modTime time.Time
+	}
+	var files []cached
+	entries, err := os.ReadDir(*cacheDir)
+	if err != nil {
+		log.Printf("缓存淘汰失败: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".json") || strings.HasSuffix(entry.Name(), ".tmp") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, cached{filepath.Join(*cacheDir, entry.Name()), info.ModTime()})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, file := range files {
+		if cacheUsed.Load() <= *cacheSize*9/10 {
+			break
+		}
+		removeCacheFile(file.name)
+	}
+}
+
+// 启动时及按 prewarm-interval 将指定前缀下的对象拉取到缓存，避免发布后首批用户等待冷缓存
+func startPrewarm() {
+	if len(prewarmPrefixes) == 0 {
+		return
+	}
+	go func() {
+		for !backendReady.Load() {
+			time.Sleep(time.Second)
+		}
+		for {
+			prewarm(context.Background())
+			if *prewarmInterval <= 0 {
+				return
+			}
+			time.Sleep(*prewarmInterval)
+		}
+	}()
+}
+
+func prewarm(ctx context.Context) {
+	start := time.Now()
+	var count, fetched int
+	var bytes int64
+	for _, prefix := range prewarmPrefixes {
+		for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: strings.TrimPrefix(prefix, "/"), Recursive: true}) {
+			if obj.Err != nil {
+				log.Printf("缓存预热列出失败: %v", obj.Err)
+				break
+			}
+			if strings.HasSuffix(obj.Key, "/") || obj.Size > *cacheMaxObjectSize {
+				continue
+			}
+			count++
+			n, err := prewarmObject(ctx, obj)
+			if err != nil {
+				log.Printf("缓存预热失败 %s: %v", obj.Key, err)
+				continue
+			}
+			if n > 0 {
+				fetched++
+				bytes += n
+			}
+		}
+	}
+	log.Printf("缓存预热完成: %d 个对象，新拉取 %d 个共 %s，耗时 %v", count, fetched, formatSize(bytes), time.Since(start).Round(time.Millisecond))
+}
+
+// 已缓存且 ETag 未变时只刷新确认时间，返回新拉取的字节数
+func prewarmObject(ctx context.Context, obj minio.ObjectInfo) (int64, error) {
+	name := cachePath(obj.Key)
+	if meta, err := readCacheMeta(name); err == nil && meta.ETag == obj.ETag {
+		if _, err := os.Stat(name); err == nil {
+			meta.Checked = time.Now()
+			return 0, writeCacheMeta(name, meta)
+		}
+	}
+	m, objectKey := resolveKey(obj.Key)
+	object, objInfo, _, err := getObject(ctx, m, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return 0, err
+	}
+	reader := newCacheWriter(obj.Key, objInfo, object)
+	n, err := io.Copy(io.Discard, reader)
+	reader.Close()
+	return n, err
+}
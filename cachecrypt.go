@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var (
+	cacheKeyFile   = flag.String("cache-key-file", "", "The file holding the master key, at least 32 bytes, that encrypts the disk cache at rest, stored in plain text when empty")
+	cacheMasterKey []byte
+)
+
+// 缓存文件格式：magic、32 字节随机盐，之后为 64 KiB 一块的 AES-GCM 密文，最后一块带结束标记防止截断
+const (
+	cacheMagic     = "B2HC1"
+	cacheSaltSize  = 32
+	cacheChunkSize = 64 << 10
+	cacheTagSize   = 16
+)
+
+func loadCacheKey() {
+	if *cacheKeyFile == "" {
+		return
+	}
+	key, err := os.ReadFile(*cacheKeyFile)
+	if err != nil {
+		log.Fatal("缓存密钥读取失败: ", err)
+	}
+	key = bytes.TrimSpace(key)
+	if len(key) < 32 {
+		log.Fatal("缓存密钥至少需要 32 字节")
+	}
+	cacheMasterKey = key
+}
+
+// 每个缓存文件的密钥由主密钥、文件自带的盐与缓存文件名派生
+func cacheAEAD(name string, salt []byte) (cipher.AEAD, error) {
+	key, err := hkdf.Key(sha256.New, cacheMasterKey, salt, "bucket2http cache "+filepath.Base(name), 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 磁盘上的文件大小
+func cacheFileSize(size int64) int64 {
+	if cacheMasterKey == nil {
+		return size
+	}
+	chunks := max(1, (size+cacheChunkSize-1)/cacheChunkSize)
+	return int64(len(cacheMagic)) + cacheSaltSize + size + chunks*cacheTagSize
+}
+
+func chunkNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+type cacheEncrypter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+}
+
+// 未配置密钥时原样写入，name 为最终的缓存文件名
+func newCacheEncrypter(w io.Writer, name string) (io.WriteCloser, error) {
+	if cacheMasterKey == nil {
+		return nopWriteCloser{w}, nil
+	}
+	salt := make([]byte, cacheSaltSize)
+	rand.Read(salt)
+	aead, err := cacheAEAD(name, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, cacheMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+	return &cacheEncrypter{w: w, aead: aead}, nil
+}
+
+// 缓冲满一块且还有后续数据时才写出，最后一块留到 Close 时标记结束
+func (e *cacheEncrypter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	for len(e.buf) > cacheChunkSize {
+		if err := e.seal(e.buf[:cacheChunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[cacheChunkSize:]
+	}
+	return len(p), nil
+}
+
+func (e *cacheEncrypter) Close() error {
+	return e.seal(e.buf, true)
+}
+
+func (e *cacheEncrypter) seal(chunk []byte, final bool) error {
+	_, err := e.w.Write(e.aead.Seal(nil, chunkNonce(e.counter, final), chunk, nil))
+	e.counter++
+	return err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+var errCacheCorrupted = errors.New("cache file corrupted")
+
+type cacheDecrypter struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	counter uint64
+	plain   []byte
+	done    bool
+}
+
+func newCacheDecrypter(r io.Reader, name string) (io.Reader, error) {
+	if cacheMasterKey == nil {
+		return r, nil
+	}
+	header := make([]byte, len(cacheMagic)+cacheSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(cacheMagic)]) != cacheMagic {
+		return nil, errCacheCorrupted
+	}
+	aead, err := cacheAEAD(name, header[len(cacheMagic):])
+	if err != nil {
+		return nil, err
+	}
+	return &cacheDecrypter{r: bufio.NewReaderSize(r, cacheChunkSize+cacheTagSize), aead: aead}, nil
+}
+
+func (d *cacheDecrypter) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		chunk := make([]byte, cacheChunkSize+cacheTagSize)
+		n, err := io.ReadFull(d.r, chunk)
+		final := false
+		switch {
+		case err == io.ErrUnexpectedEOF:
+			final = true
+		case err != nil:
+			// 缺少结束块说明文件被截断
+			return 0, errCacheCorrupted
+		default:
+			_, perr := d.r.Peek(1)
+			final = perr == io.EOF
+		}
+		plain, err := d.aead.Open(chunk[:0], chunkNonce(d.counter, final), chunk[:n], nil)
+		if err != nil {
+			return 0, errCacheCorrupted
+		}
+		d.counter++
+		d.plain, d.done = plain, final
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// 整体加密的小文件，用于元数据
+func sealCacheBlob(name string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := newCacheEncrypter(&buf, name)
+	if err != nil {
+		return nil, err
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func openCacheBlob(name string, data []byte) ([]byte, error) {
+	r, err := newCacheDecrypter(bytes.NewReader(data), name)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	canaryKey      = flag.String("canary-key", "", "The key of a small object fetched periodically, /readyz returns 503 while fetching it fails, disabled when empty")
+	canaryInterval = flag.Duration("canary-interval", 30*time.Second, "How often the canary object is fetched")
+	canaryBudget   = flag.Duration("canary-budget", 2*time.Second, "The longest a canary fetch may take before the instance is marked unready")
+	canaryFailing  atomic.Bool
+)
+
+// 定期完整读取探测对象，覆盖后端可达但数据读取异常的情况
+func startCanary() {
+	if *canaryKey == "" {
+		return
+	}
+	// 首次探测成功前视为未就绪
+	canaryFailing.Store(true)
+	go func() {
+		checked := false
+		for {
+			if backendReady.Load() {
+				err := checkCanary()
+				// 只在状态变化时记录日志
+				if err != nil && (!checked || !canaryFailing.Load()) {
+					log.Printf("探测对象读取失败，标记为未就绪: %v", err)
+				} else if err == nil && checked && canaryFailing.Load() {
+					log.Println("探测对象读取恢复")
+				}
+				canaryFailing.Store(err != nil)
+				checked = true
+			}
+			time.Sleep(*canaryInterval)
+		}
+	}()
+}
+
+func checkCanary() error {
+	start := time.Now()
+	err := fetchCanary()
+	if err == nil && time.Since(start) > *canaryBudget {
+		err = errors.New("exceeded latency budget")
+	}
+	return err
+}
+
+func fetchCanary() error {
+	key := strings.TrimPrefix(*canaryKey, "/")
+	if findMount(key) == nil {
+		return errors.New("canary key is outside mounts")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *canaryBudget)
+	defer cancel()
+	m, objectKey := resolveKey(key)
+	object, _, _, err := getObject(ctx, m, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+	_, err = io.Copy(io.Discard, object)
+	return err
+}
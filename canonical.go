@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var canonicalURL = flag.String("canonical-url", "", "The canonical base URL such as https://mirror.example.com, other hosts and schemes are redirected to it")
+
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	// 经由反向代理终止 TLS 的情况，只信任 -trusted-proxy 中的代理
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil || !trustedProxies.contains(peer) {
+		return "http"
+	}
+	switch proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto {
+	case "http", "https":
+		return proto
+	}
+	return "http"
+}
+
+// 非规范主机名或协议的请求 301 重定向到规范地址
+func withCanonicalHost(next http.Handler) http.Handler {
+	if *canonicalURL == "" {
+		return next
+	}
+	canonical, err := url.Parse(*canonicalURL)
+	if err != nil || canonical.Scheme == "" || canonical.Host == "" {
+		log.Fatalf("规范地址无效: %s", *canonicalURL)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == canonical.Host && requestScheme(r) == canonical.Scheme {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.Redirect(w, r, canonical.Scheme+"://"+canonical.Host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	textCharset         = flag.String("text-charset", "utf-8", "The charset appended to text content types that have none, disabled when empty")
+	textCharsetFallback = flag.String("text-charset-fallback", "", "The charset used instead when the start of a text object is not valid UTF-8, e.g. gb18030, not detected when empty")
+)
+
+// 检测字符集时读取的开头字节数
+const charsetSniffLen = 4096
+
+// 文本类型（text/*、JSON、JavaScript、XML）
+func isTextType(mediaType string) bool {
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// 为没有 charset 参数的文本类型补充字符集，需要检测时返回的 reader 替代 object
+func withCharset(contentType string, object io.Reader) (string, io.Reader) {
+	if *textCharset == "" {
+		return contentType, object
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !isTextType(mediaType) || params["charset"] != "" {
+		return contentType, object
+	}
+	charset := *textCharset
+	if *textCharsetFallback != "" {
+		br := bufio.NewReaderSize(object, charsetSniffLen)
+		head, _ := br.Peek(charsetSniffLen)
+		if !validUTF8Prefix(head, len(head) == charsetSniffLen) {
+			charset = *textCharsetFallback
+		}
+		object = br
+	}
+	params["charset"] = charset
+	return mime.FormatMediaType(mediaType, params), object
+}
+
+// 开头是否为合法 UTF-8，truncated 时忽略末尾被截断的字符
+func validUTF8Prefix(head []byte, truncated bool) bool {
+	if truncated {
+		for i := len(head) - 1; i >= 0 && i >= len(head)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(head[i]) {
+				if !utf8.FullRune(head[i:]) {
+					head = head[:i]
+				}
+				break
+			}
+		}
+	}
+	return utf8.Valid(head)
+}
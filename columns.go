@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+type Column struct {
+	ID    string
+	Title string
+}
+
+// 目录列表可选的列
+var knownColumns = []Column{
+	{"name", "Name"},
+	{"size", "Size"},
+	{"mtime", "Last Modified"},
+	{"etag", "ETag"},
+	{"class", "Storage Class"},
+	{"type", "Content Type"},
+	{"owner", "Owner"},
+	{"downloads", "Downloads"},
+}
+
+// 逗号分隔的列名参数
+type columnList []Column
+
+func (c *columnList) String() string {
+	var ids []string
+	for _, col := range *c {
+		ids = append(ids, col.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func (c *columnList) Set(value string) error {
+	var columns columnList
+	for _, id := range splitList(value) {
+		col, ok := findColumn(id)
+		if !ok {
+			return fmt.Errorf("unknown column %q", id)
+		}
+		columns = append(columns, col)
+	}
+	// 名称列始终保留，否则无法点击进入
+	if !columns.has("name") {
+		columns = append(columnList{knownColumns[0]}, columns...)
+	}
+	*c = columns
+	return nil
+}
+
+func (c columnList) has(id string) bool {
+	for _, col := range c {
+		if col.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func findColumn(id string) (Column, bool) {
+	for _, col := range knownColumns {
+		if col.ID == id {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+var listColumns columnList
+
+func init() {
+	listColumns.Set("name,size,mtime")
+	flag.Var(&listColumns, "columns", "The columns and their order in directory listings, from name,size,mtime,etag,class,type,owner,downloads")
+}
+
+// 当前请求使用的列
+func requestColumns(showClass bool) columnList {
+	if showClass && !listColumns.has("class") {
+		columns := append(columnList{}, listColumns...)
+		col, _ := findColumn("class")
+		return append(columns, col)
+	}
+	return listColumns
+}
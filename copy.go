@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 解析 Destination 请求头得到目标 key
+func destinationKey(r *http.Request) (string, bool) {
+	dest := r.Header.Get("Destination")
+	if dest == "" {
+		return "", false
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", false
+	}
+	return aliasKey(strings.TrimPrefix(u.Path, "/")), true
+}
+
+// 元数据处理方式：默认沿用源对象元数据，X-Metadata-Directive: REPLACE 时使用请求中的元数据
+func copyMetadata(r *http.Request) (map[string]string, bool) {
+	if !strings.EqualFold(r.Header.Get("X-Metadata-Directive"), "REPLACE") {
+		return nil, false
+	}
+	metadata := map[string]string{}
+	for name := range r.Header {
+		if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+			metadata[name] = r.Header.Get(name)
+		}
+	}
+	for _, name := range []string{"Content-Type", "Content-Disposition", "Content-Encoding", "Cache-Control"} {
+		if value := r.Header.Get(name); value != "" {
+			metadata[name] = value
+		}
+	}
+	return metadata, true
+}
+
+// 单次 CopyObject 支持的最大对象大小
+const maxCopySize = 5 << 30
+
+// 服务端复制单个对象，超过 5GiB 时使用分片复制
+func copyObject(ctx context.Context, r *http.Request, src, dst string) error {
+	metadata, replace := copyMetadata(r)
+	srcMount, srcKey := resolveKey(src)
+	dstMount, dstKey := resolveKey(dst)
+	dstOpts := minio.CopyDestOptions{Bucket: dstMount.Bucket, Object: dstKey, UserMetadata: metadata, ReplaceMetadata: replace}
+	srcOpts := minio.CopySrcOptions{Bucket: srcMount.Bucket, Object: srcKey}
+
+	objInfo, err := statObject(ctx, srcMount, srcKey, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	// 不同后端之间无法服务端复制，经由本机中转
+	if srcMount.backend != dstMount.backend {
+		return relayObject(ctx, srcMount, srcKey, dstMount, dstKey, objInfo, metadata, replace)
+	}
+	if objInfo.Size <= maxCopySize {
+		_, err = dstMount.client().CopyObject(ctx, dstOpts, srcOpts)
+		return err
+	}
+
+	// 分片复制不会沿用源对象的内容类型，需要显式带上
+	if !replace {
+		dstOpts.ReplaceMetadata = true
+		dstOpts.UserMetadata = map[string]string{"Content-Type": objInfo.ContentType}
+		for k, v := range objInfo.UserMetadata {
+			dstOpts.UserMetadata[k] = v
+		}
+	}
+	_, err = dstMount.client().ComposeObject(ctx, dstOpts, srcOpts)
+	return err
+}
+
+func relayObject(ctx context.Context, srcMount *Mount, srcKey string, dstMount *Mount, dstKey string, objInfo minio.ObjectInfo, metadata map[string]string, replace bool) error {
+	object, _, _, err := getObject(ctx, srcMount, srcKey, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	opts := minio.PutObjectOptions{ContentType: objInfo.ContentType, UserMetadata: objInfo.UserMetadata}
+	if replace {
+		opts = minio.PutObjectOptions{UserMetadata: metadata}
+	}
+	_, err = dstMount.client().PutObject(ctx, dstMount.Bucket, dstKey, object, objInfo.Size, opts)
+	return err
+}
+
+// 两个 key 解析后位于同一后端、同一存储桶时返回各自的对象 key
+func sameBucket(a, b string) (string, string, bool) {
+	ma, ka := resolveKey(a)
+	mb, kb := resolveKey(b)
+	return ka, kb, ma.backend == mb.backend && ma.Bucket == mb.Bucket
+}
+
+func handleCopy(w http.ResponseWriter, r *http.Request, key string) {
+	transferObjects(w, r, key, false)
+}
+
+func handleMove(w http.ResponseWriter, r *http.Request, key string) {
+	transferObjects(w, r, key, true)
+}
+
+// 复制或移动文件/目录，移动时在复制完成后删除源对象
+func transferObjects(w http.ResponseWriter, r *http.Request, key string, move bool) {
+	dest, ok := destinationKey(r)
+	if !ok || key == "" {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+	ctx := context.Background()
+
+	// 单个文件
+	if !strings.HasSuffix(key, "/") {
+		m, objectKey := resolveKey(key)
+		if objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{}); err == nil {
+			if !canRead(r, key) {
+				http.Error(w, "403 Forbidden", http.StatusForbidden)
+				return
+			}
+			if dest == "" || strings.HasSuffix(dest, "/") {
+				http.Error(w, "400 Bad Request", http.StatusBadRequest)
+				return
+			}
+			// 目标经别名或路由解析后可能就是源对象，移动时会删除唯一的副本
+			if src, dst, same := sameBucket(key, dest); same && src == dst {
+				http.Error(w, "403 Forbidden", http.StatusForbidden)
+				return
+			}
+			// 移动前确认源对象未被锁定，避免复制后删除失败
+			if move && !checkUnlocked(ctx, w, []string{key}) {
+				return
+			}
+			reservation, _, ok := reserveQuota(r, objInfo.Size)
+			if !ok {
+				http.Error(w, "507 Insufficient Storage", http.StatusInsufficientStorage)
+				return
+			}
+			if err := copyObject(ctx, r, key, dest); err != nil {
+				reservation.settle(0)
+				log.Printf("文件复制失败: %v", err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if move {
+				if err := m.client().RemoveObject(ctx, m.Bucket, objectKey, minio.RemoveObjectOptions{}); err != nil {
+					log.Printf("文件删除失败: %v", err)
+					http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		key += "/"
+	}
+
+	// 整个目录
+	dest = strings.TrimSuffix(dest, "/")
+	if dest != "" {
+		dest += "/"
+	}
+	// 按解析后的位置比较，避免经别名或路由把目录复制到自身之下
+	if src, dst, same := sameBucket(key, dest); same && strings.HasPrefix(dst, src) {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var keys []string
+	var total int64
+	sizes := map[string]int64{}
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: key, Recursive: true}) {
+		if obj.Err != nil {
+			log.Printf("目录列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		// 访问规则对象与无权读取的对象不随目录复制
+		if !canRead(r, obj.Key) {
+			continue
+		}
+		keys = append(keys, obj.Key)
+		sizes[obj.Key] = obj.Size
+		total += obj.Size
+	}
+	if len(keys) == 0 {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	if move && !checkUnlocked(ctx, w, keys) {
+		return
+	}
+	reservation, _, ok := reserveQuota(r, total)
+	if !ok {
+		http.Error(w, "507 Insufficient Storage", http.StatusInsufficientStorage)
+		return
+	}
+
+	// 逐个复制并输出进度，进度输出后状态码已无法更改，结果通过 X-Transfer-Status 尾部返回
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Trailer", "X-Transfer-Status")
+	flusher, _ := w.(http.Flusher)
+	var copied []string
+	var written int64
+	failed := false
+	for i, k := range keys {
+		target := dest + strings.TrimPrefix(k, key)
+		if err := copyObject(ctx, r, k, target); err != nil {
+			log.Printf("文件复制失败: %v", err)
+			// 尚未复制任何对象时直接返回错误状态
+			if i == 0 {
+				reservation.settle(0)
+				w.Header().Del("Trailer")
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprintf(w, "[%d/%d] %s: failed: %v\n", i+1, len(keys), k, err)
+			failed = true
+			break
+		}
+		copied = append(copied, k)
+		written += sizes[k]
+		fmt.Fprintf(w, "[%d/%d] %s -> %s\n", i+1, len(keys), k, target)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	reservation.settle(written)
+
+	// 全部复制成功后才批量删除源对象，部分失败时保留全部源对象
+	switch {
+	case failed && move:
+		fmt.Fprintf(w, "%d of %d copied, sources kept\n", len(copied), len(keys))
+	case failed:
+		fmt.Fprintf(w, "%d of %d copied\n", len(copied), len(keys))
+	case move && removeObjects(ctx, copied) > 0:
+		fmt.Fprintln(w, "copied, some sources could not be removed")
+		failed = true
+	}
+	if failed {
+		w.Header().Set("X-Transfer-Status", "failed")
+	} else {
+		w.Header().Set("X-Transfer-Status", "complete")
+	}
+}
+
+// 按存储位置分组批量删除对象，返回删除失败的数量
+func removeObjects(ctx context.Context, keys []string) int {
+	failures := 0
+	groups := map[*Mount][]string{}
+	for _, k := range keys {
+		m, objectKey := resolveKey(k)
+		groups[m] = append(groups[m], objectKey)
+	}
+	for m, objectKeys := range groups {
+		objectsCh := make(chan minio.ObjectInfo)
+		go func() {
+			defer close(objectsCh)
+			for _, objectKey := range objectKeys {
+				objectsCh <- minio.ObjectInfo{Key: objectKey}
+			}
+		}()
+		for err := range m.client().RemoveObjects(ctx, m.Bucket, objectsCh, minio.RemoveObjectsOptions{}) {
+			log.Printf("文件删除失败: %s: %v", err.ObjectName, err.Err)
+			failures++
+		}
+	}
+	return failures
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMoveFile(t *testing.T) {
+	enableWrites(t, credentialList{"u": "p"})
+	base := startTestGateway(t, map[string]string{"d/a.txt": "a"})
+
+	resp, _ := doRequest(t, "MOVE", base+"/d/a.txt", "u", http.Header{"Destination": {"/e/b.txt"}})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE: %s", resp.Status)
+	}
+	if resp, _ := doRequest(t, http.MethodGet, base+"/d/a.txt", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("source after MOVE: %s", resp.Status)
+	}
+	if _, body := doRequest(t, http.MethodGet, base+"/e/b.txt", "", nil); body != "a" {
+		t.Errorf("destination after MOVE: got %q", body)
+	}
+}
+
+// 目标经别名解析后就是源对象时，移动不能删除唯一的副本
+func TestMoveOntoItself(t *testing.T) {
+	enableWrites(t, credentialList{"u": "p"})
+	old := aliases
+	aliases = prefixMap{{Prefix: "al/", Value: "d/"}}
+	t.Cleanup(func() { aliases = old })
+	base := startTestGateway(t, map[string]string{"d/a.txt": "a"})
+
+	for _, dest := range []string{"/d/a.txt", "/al/a.txt"} {
+		header := http.Header{"Destination": {dest}, "X-Metadata-Directive": {"REPLACE"}}
+		if resp, _ := doRequest(t, "MOVE", base+"/d/a.txt", "u", header); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("MOVE to %s: %s", dest, resp.Status)
+		}
+		if _, body := doRequest(t, http.MethodGet, base+"/d/a.txt", "", nil); body != "a" {
+			t.Fatalf("source after MOVE to %s: got %q", dest, body)
+		}
+	}
+
+	// 目录不能移动到自身之下，经别名也不行
+	for _, dest := range []string{"/d/sub/", "/al/sub/"} {
+		if resp, _ := doRequest(t, "MOVE", base+"/d/", "u", http.Header{"Destination": {dest}}); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("MOVE /d/ to %s: %s", dest, resp.Status)
+		}
+	}
+}
+
+func TestMoveDirectory(t *testing.T) {
+	enableWrites(t, credentialList{"u": "p"})
+	base := startTestGateway(t, map[string]string{
+		"d/a.txt":     "a",
+		"d/sub/b.txt": "b",
+		"d/.access":   "read: *",
+	})
+
+	resp, _ := doRequest(t, "MOVE", base+"/d/", "u", http.Header{"Destination": {"/e/"}})
+	if resp.StatusCode != http.StatusOK || resp.Trailer.Get("X-Transfer-Status") != "complete" {
+		t.Fatalf("MOVE: %s, X-Transfer-Status %q", resp.Status, resp.Trailer.Get("X-Transfer-Status"))
+	}
+	for name, content := range map[string]string{"e/a.txt": "a", "e/sub/b.txt": "b"} {
+		if _, body := doRequest(t, http.MethodGet, base+"/"+name, "", nil); body != content {
+			t.Errorf("%s: got %q, want %q", name, body, content)
+		}
+	}
+	for _, name := range []string{"d/a.txt", "d/sub/b.txt"} {
+		if resp, _ := doRequest(t, http.MethodGet, base+"/"+name, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s after MOVE: %s", name, resp.Status)
+		}
+	}
+	// 访问规则对象不随目录移动
+	if resp, _ := doRequest(t, http.MethodGet, base+"/e/.access", "u", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("e/.access after MOVE: %s", resp.Status)
+	}
+}
+
+// 未配置任何用户时，复制与移动同样需要认证
+func TestTransferRequiresUser(t *testing.T) {
+	enableWrites(t, credentialList{})
+	base := startTestGateway(t, map[string]string{"d/a.txt": "a"})
+
+	for _, method := range []string{"COPY", "MOVE"} {
+		if resp, _ := doRequest(t, method, base+"/d/a.txt", "", http.Header{"Destination": {"/e/a.txt"}}); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("anonymous %s: %s", method, resp.Status)
+		}
+	}
+	if resp, _ := doRequest(t, http.MethodGet, base+"/e/a.txt", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("destination after refused transfers: %s", resp.Status)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	countsStore    = flag.String("download-counts", "", "Where download counts are persisted, a file path or bucket:key, disabled when empty")
+	countsInterval = flag.Duration("download-counts-interval", time.Minute, "How often download counts are persisted")
+)
+
+var counters struct {
+	sync.Mutex
+	counts map[string]int64
+	dirty  bool
+}
+
+func countersEnabled() bool {
+	return *countsStore != ""
+}
+
+func startCounters() error {
+	if !countersEnabled() || counters.counts != nil {
+		return nil
+	}
+	counts := map[string]int64{}
+	data, err := loadState(*countsStore)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &counts); err != nil {
+			return err
+		}
+	}
+	counters.counts = counts
+	go func() {
+		for range time.Tick(*countsInterval) {
+			saveCounts()
+		}
+	}()
+	return nil
+}
+
+func saveCounts() {
+	counters.Lock()
+	if !counters.dirty {
+		counters.Unlock()
+		return
+	}
+	data, err := json.Marshal(counters.counts)
+	counters.dirty = false
+	counters.Unlock()
+	if err != nil {
+		log.Printf("下载计数保存失败: %v", err)
+		return
+	}
+
+	if err := saveState(*countsStore, data); err != nil {
+		log.Printf("下载计数保存失败: %v", err)
+		counters.Lock()
+		counters.dirty = true
+		counters.Unlock()
+	}
+}
+
+// 每次完整下载计一次
+func countDownload(key string, status int) {
+	if !countersEnabled() || status != http.StatusOK {
+		return
+	}
+	counters.Lock()
+	counters.counts[key]++
+	counters.dirty = true
+	counters.Unlock()
+}
+
+func downloadCount(key string) int64 {
+	counters.Lock()
+	defer counters.Unlock()
+	return counters.counts[key]
+}
+
+// 为列表中的文件填充下载次数
+func fillDownloads(entries []DirEntry) {
+	if !countersEnabled() {
+		return
+	}
+	counters.Lock()
+	defer counters.Unlock()
+	for i := range entries {
+		if !entries[i].IsDir {
+			entries[i].Downloads = counters.counts[entries[i].Key]
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	scriptPattern = regexp.MustCompile(`(?is)<script.*?</script>`)
+	tagPattern    = regexp.MustCompile(`(?is)<(a|link|img|script|iframe|frame|embed)\b[^>]*>`)
+	attrPattern   = regexp.MustCompile(`(?is)\b(href|src|rel)\s*=\s*"([^"]*)"`)
+)
+
+// 与 wget -r 相同的方式提取链接：不解析脚本内容，跳过 rel="nofollow"
+func pageLinks(page string) []string {
+	var links []string
+	for _, tag := range tagPattern.FindAllString(scriptPattern.ReplaceAllString(page, ""), -1) {
+		var link string
+		nofollow := false
+		for _, attr := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			switch strings.ToLower(attr[1]) {
+			case "rel":
+				nofollow = strings.Contains(strings.ToLower(attr[2]), "nofollow")
+			default:
+				link = attr[2]
+			}
+		}
+		if link != "" && !nofollow {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// 启动本地目录后端与网关，返回网关地址
+func startTestGateway(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backend, err := newLocalBackend(handlerTransport{&fsServer{root: root, uploads: t.TempDir()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaultBackend = backend
+	if err := setupMounts(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// 各测试的目录内容不同，不能沿用上一个测试缓存的访问规则
+	resetAccessCache()
+	gateway := httptest.NewServer(withUser(newMux()))
+	t.Cleanup(gateway.Close)
+	return gateway.URL
+}
+
+// 在测试期间修改单值的命令行参数，结束后恢复
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	old := flag.Lookup(name).Value.String()
+	if err := flag.Set(name, value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flag.Set(name, old) })
+}
+
+// 开启写操作并配置可写用户
+func enableWrites(t *testing.T, users credentialList) {
+	t.Helper()
+	setFlag(t, "write", "true")
+	setFlag(t, "read-only", "false")
+	old := writeUsers
+	writeUsers = users
+	t.Cleanup(func() { writeUsers = old })
+}
+
+// 发送请求并读取响应内容，user 非空时以 user:p 认证
+func doRequest(t *testing.T, method, target, user string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if user != "" {
+		req.SetBasicAuth(user, "p")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+// wget -r -np 从目录页出发应能取得其下全部文件，且不会抓取带查询参数的页面
+func TestWgetMirror(t *testing.T) {
+	files := map[string]string{
+		"outside.txt":          "outside",
+		"pub/a.txt":            "a",
+		"pub/docs/b.txt":       "b",
+		"pub/docs/deep/c.txt":  "c",
+		"pub/images/d.png":     "\x89PNG\r\n\x1a\n",
+		"pub/images/e.png":     "\x89PNG\r\n\x1a\n",
+		"pub/name with space":  "f",
+		"pub/docs/deep/g.html": "<p>g</p>",
+	}
+	base := startTestGateway(t, files)
+	start, _ := url.Parse(base + "/pub/")
+
+	seen := map[string]bool{start.String(): true}
+	queue := []*url.URL{start}
+	fetched := map[string]string{}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		if u.RawQuery != "" {
+			t.Errorf("crawled a URL with a query string: %s", u)
+		}
+		req, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+		req.Header.Set("User-Agent", "Wget/1.21.4")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: %s", u, resp.Status)
+			continue
+		}
+		fetched[u.Path] = string(body)
+		if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || !strings.HasSuffix(u.Path, "/") {
+			continue
+		}
+		for _, link := range pageLinks(string(body)) {
+			ref, err := u.Parse(link)
+			if err != nil {
+				t.Errorf("invalid link %q on %s", link, u)
+				continue
+			}
+			ref.Fragment = ""
+			// -np：不进入起始目录之外
+			if ref.Host != start.Host || !strings.HasPrefix(ref.Path, start.Path) || seen[ref.String()] {
+				continue
+			}
+			seen[ref.String()] = true
+			queue = append(queue, ref)
+		}
+	}
+
+	for name, content := range files {
+		got, ok := fetched["/"+name]
+		switch {
+		case !strings.HasPrefix(name, "pub/"):
+			if ok {
+				t.Errorf("crawled %s outside the start directory", name)
+			}
+		case !ok:
+			t.Errorf("%s was not reached", name)
+		case got != content:
+			t.Errorf("%s: got %q, want %q", name, got, content)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"flag"
+	"net/http"
+)
+
+const (
+	csrfCookie = "bucket2http_csrf"
+	csrfHeader = "X-CSRF-Token"
+)
+
+var csrfEnabled = flag.Bool("csrf", true, "Require the CSRF token of the file manager pages on writes sent by browsers")
+
+// 页面中嵌入的令牌，与 Cookie 中的值相同（双重提交），没有时生成新的
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	buf := make([]byte, 32)
+	rand.Read(buf)
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// 浏览器发出的写请求（带 Origin、Sec-Fetch-Site 或会话 Cookie）必须带有与 Cookie 一致的令牌，
+// curl 等非浏览器客户端不受影响
+func checkCSRF(w http.ResponseWriter, r *http.Request) bool {
+	if !*csrfEnabled {
+		return true
+	}
+	_, err := r.Cookie(sessionCookie)
+	if r.Header.Get("Origin") == "" && r.Header.Get("Sec-Fetch-Site") == "" && err != nil {
+		return true
+	}
+	cookie, err := r.Cookie(csrfCookie)
+	token := r.Header.Get(csrfHeader)
+	if err != nil || token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+		logSecurity(r, "csrf")
+		http.Error(w, "403 Forbidden: invalid CSRF token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	dirSizes   = flag.Bool("dir-sizes", false, "Show the total size of each subdirectory in listings, computed in the background and cached")
+	dirSizeTTL = flag.Duration("dir-size-ttl", 10*time.Minute, "How long a computed directory size is cached")
+)
+
+type dirSize struct {
+	objects  int64
+	bytes    int64
+	computed time.Time
+}
+
+var dirSizeCache struct {
+	sync.Mutex
+	sizes   map[string]dirSize
+	pending map[string]bool
+}
+
+const (
+	// 同时进行的目录统计数，避免打开大目录时后端压力过大
+	dirSizeWorkers = 2
+	// 等待统计的目录数上限，队列已满时留到下次访问
+	dirSizeQueueLen = 256
+	// 缓存的目录大小数量上限
+	maxDirSizes = 10000
+)
+
+var (
+	dirSizeQueue   = make(chan string, dirSizeQueueLen)
+	dirSizeStarted sync.Once
+)
+
+// 为子目录填入已缓存的大小，尚未计算的仍显示为 -
+func fillDirSizes(entries []DirEntry) {
+	if !*dirSizes {
+		return
+	}
+	for i := range entries {
+		if !entries[i].IsDir {
+			continue
+		}
+		if size, ok := cachedDirSize(entries[i].Key); ok {
+			entries[i].Size, entries[i].Bytes = formatSize(size.bytes), size.bytes
+		}
+	}
+}
+
+// 没有或已过期时排队重新计算，计算完成前继续使用过期的值
+func cachedDirSize(prefix string) (dirSize, bool) {
+	dirSizeStarted.Do(func() {
+		for range dirSizeWorkers {
+			go func() {
+				for prefix := range dirSizeQueue {
+					computeDirSize(prefix)
+				}
+			}()
+		}
+	})
+
+	dirSizeCache.Lock()
+	defer dirSizeCache.Unlock()
+	if dirSizeCache.sizes == nil {
+		dirSizeCache.sizes = map[string]dirSize{}
+		dirSizeCache.pending = map[string]bool{}
+	}
+	size, ok := dirSizeCache.sizes[prefix]
+	if (!ok || time.Since(size.computed) > *dirSizeTTL) && !dirSizeCache.pending[prefix] {
+		select {
+		case dirSizeQueue <- prefix:
+			dirSizeCache.pending[prefix] = true
+		default:
+		}
+	}
+	return size, ok
+}
+
+func computeDirSize(prefix string) {
+	size, err := sumPrefix(context.Background(), prefix)
+	dirSizeCache.Lock()
+	defer dirSizeCache.Unlock()
+	delete(dirSizeCache.pending, prefix)
+	if err != nil {
+		log.Printf("目录大小统计失败: %v", err)
+		return
+	}
+	if _, ok := dirSizeCache.sizes[prefix]; !ok && len(dirSizeCache.sizes) >= maxDirSizes {
+		evictDirSizes()
+	}
+	dirSizeCache.sizes[prefix] = size
+}
+
+// 缓存已满时先丢弃过期的值，仍然已满时随机丢弃一条；调用方需持有锁
+func evictDirSizes() {
+	for prefix, size := range dirSizeCache.sizes {
+		if time.Since(size.computed) > *dirSizeTTL {
+			delete(dirSizeCache.sizes, prefix)
+		}
+	}
+	for prefix := range dirSizeCache.sizes {
+		if len(dirSizeCache.sizes) < maxDirSizes {
+			break
+		}
+		delete(dirSizeCache.sizes, prefix)
+	}
+}
+
+// 统计前缀下全部对象的数量与大小
+func sumPrefix(ctx context.Context, prefix string) (dirSize, error) {
+	var size dirSize
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return size, obj.Err
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		size.objects++
+		size.bytes += obj.Size
+	}
+	size.computed = time.Now()
+	return size, nil
+}
+
+// 写入后各级上级目录的大小在下次访问时重新计算
+func expireDirSizes(key string) {
+	dirSizeCache.Lock()
+	defer dirSizeCache.Unlock()
+	for prefix, size := range dirSizeCache.sizes {
+		if strings.HasPrefix(key, prefix) {
+			size.computed = time.Time{}
+			dirSizeCache.sizes[prefix] = size
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+//go:embed icons/favicon.ico
+var embeddedFavicon []byte
+
+var faviconKey = flag.String("favicon", "", "The key of an object served as /favicon.ico, the built-in icon is used when empty or missing")
+
+// 浏览器会自动请求 /favicon.ico，直接返回内置图标，避免每次访问后端
+func handleFavicon(w http.ResponseWriter, r *http.Request) {
+	if *faviconKey != "" && backendReady.Load() && serveFaviconObject(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "image/x-icon")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, "favicon.ico", faviconModTime, bytes.NewReader(embeddedFavicon))
+}
+
+func serveFaviconObject(w http.ResponseWriter, r *http.Request) bool {
+	key := strings.TrimPrefix(*faviconKey, "/")
+	if findMount(key) == nil {
+		return false
+	}
+	m, objectKey := resolveKey(key)
+	object, objInfo, _, err := getObject(r.Context(), m, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+			log.Printf("图标获取失败: %v", err)
+		}
+		return false
+	}
+	defer object.Close()
+	w.Header().Set("Content-Type", objectContentType(key, objInfo.ContentType))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", `"`+objInfo.ETag+`"`)
+	w.Header().Set("Last-Modified", objInfo.LastModified.UTC().Format(http.TimeFormat))
+	if r.Method != http.MethodHead {
+		io.Copy(w, object)
+	}
+	return true
+}
+
+// 内置图标的修改时间
+var faviconModTime = time.Now()
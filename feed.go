@@ -0,0 +1,128 @@
+package main
+
+import (
+	"container/heap"
+	"context"
+	"encoding/xml"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var feedSize = flag.Int("feed-size", 50, "The number of newest objects in the feed")
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string   `xml:"title"`
+	ID      string   `xml:"id"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Link    atomLink    `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// 请求对应的站点根地址
+func baseURL(r *http.Request) string {
+	return requestScheme(r) + "://" + r.Host
+}
+
+// 输出目录下最新文件的 Atom 订阅
+func handleFeed(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+	if !listingAllowed(prefix) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkHome(w, r, prefix, false) {
+		return
+	}
+
+	// 只保留最新的 feedSize 个对象，堆顶为其中最旧的一个
+	newest := &objectHeap{}
+	for obj := range listObjects(context.Background(), minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			log.Printf("目录列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if *feedSize <= 0 || (newest.Len() == *feedSize && !obj.LastModified.After((*newest)[0].LastModified)) {
+			continue
+		}
+		if strings.HasSuffix(obj.Key, "/") || !keyListable(obj.Key) || !canRead(r, obj.Key) {
+			continue
+		}
+		heap.Push(newest, obj)
+		if newest.Len() > *feedSize {
+			heap.Pop(newest)
+		}
+	}
+
+	// 按修改时间倒序输出
+	objects := make([]minio.ObjectInfo, newest.Len())
+	for i := len(objects) - 1; i >= 0; i-- {
+		objects[i] = heap.Pop(newest).(minio.ObjectInfo)
+	}
+
+	base := baseURL(r)
+	feed := atomFeed{
+		Title:   "Index of /" + prefix,
+		ID:      base + keyURL(prefix),
+		Link:    atomLink{Href: base + keyURL(prefix)},
+		Updated: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(objects) > 0 {
+		feed.Updated = objects[0].LastModified.UTC().Format(time.RFC3339)
+	}
+	for _, obj := range objects {
+		url := base + keyURL(obj.Key)
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   strings.TrimPrefix(obj.Key, prefix),
+			ID:      url + "#" + obj.ETag,
+			Link:    atomLink{Href: url, Rel: "enclosure"},
+			Updated: obj.LastModified.UTC().Format(time.RFC3339),
+			Summary: formatSize(obj.Size),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		log.Printf("响应写入失败: %v", err)
+	}
+}
+
+// 按修改时间排列的小顶堆
+type objectHeap []minio.ObjectInfo
+
+func (h objectHeap) Len() int           { return len(h) }
+func (h objectHeap) Less(i, j int) bool { return h[i].LastModified.Before(h[j].LastModified) }
+func (h objectHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *objectHeap) Push(x any) {
+	*h = append(*h, x.(minio.ObjectInfo))
+}
+
+func (h *objectHeap) Pop() any {
+	old := *h
+	obj := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return obj
+}
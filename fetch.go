@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	fetchAllow    stringList
+	fetchTypes    stringList
+	fetchMaxSize  = flag.Int64("fetch-max-size", 1<<30, "The most bytes stored by one upload from URL, 0 means only -max-body-size and -max-upload-size apply")
+	fetchTimeout  = flag.Duration("fetch-timeout", 10*time.Minute, "The longest time an upload from URL may take")
+	errFetchLocal = errors.New("fetching from a loopback, private or multicast address is not allowed")
+
+	// 运营商级 NAT 地址段
+	cgnatNet = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+)
+
+func init() {
+	flag.Var(&fetchAllow, "fetch-allow", "The scheme://host[:port]/path prefix uploads from URL may fetch, any public http(s) address is allowed when unset, can be repeated")
+	flag.Var(&fetchTypes, "fetch-type", "The content type pattern like image/* an upload from URL must match, any type is allowed when unset, can be repeated")
+}
+
+// 始终拒绝连接本机、内网、链路本地、CGNAT 与组播地址，重定向后的地址同样检查
+var fetchClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 30 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, _ := net.SplitHostPort(address)
+				ip := net.ParseIP(host)
+				if fetchBlockedIP(ip) {
+					return errFetchLocal
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !fetchAllowed(req.URL) {
+			return errors.New("redirect to a URL that is not allowed")
+		}
+		return nil
+	},
+}
+
+func fetchBlockedIP(ip net.IP) bool {
+	return ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		ip.IsMulticast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || cgnatNet.Contains(ip)
+}
+
+// 按协议、完整主机名与路径前缀匹配白名单，不接受带用户信息的 URL
+func fetchAllowed(u *url.URL) bool {
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return false
+	}
+	if len(fetchAllow) == 0 {
+		return true
+	}
+	clean := path.Clean("/" + u.Path)
+	for _, item := range fetchAllow {
+		allowed, err := url.Parse(item)
+		if err != nil || allowed.Scheme != u.Scheme || !strings.EqualFold(allowed.Host, u.Host) {
+			continue
+		}
+		prefix := strings.TrimSuffix(allowed.Path, "/")
+		if prefix == "" || clean == prefix || strings.HasPrefix(clean, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func fetchTypeAllowed(contentType string) bool {
+	if len(fetchTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, pattern := range fetchTypes {
+		if ok, _ := path.Match(pattern, mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// POST /key?fetch=URL 由服务端下载 URL 的内容存为 key，大小取各项限制中最小的
+func handleFetch(w http.ResponseWriter, r *http.Request, key string) {
+	source := r.URL.Query().Get("fetch")
+	u, err := url.Parse(source)
+	if key == "" || strings.HasSuffix(key, "/") || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+	if !fetchAllowed(u) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+
+	limit := *fetchMaxSize
+	if l := bodyLimit(key); l > 0 && (limit <= 0 || l < limit) {
+		limit = l
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), *fetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		log.Printf("URL 下载失败: %v", err)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("URL 下载失败: %s 返回 %s", source, resp.Status)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	// 只按上游声明的类型检查，不使用 key 的扩展名
+	if !fetchTypeAllowed(resp.Header.Get("Content-Type")) {
+		http.Error(w, "415 Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if limit > 0 && resp.ContentLength > limit {
+		http.Error(w, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	reservation, remaining, ok := reserveQuota(r, resp.ContentLength)
+	if !ok {
+		http.Error(w, "507 Insufficient Storage", http.StatusInsufficientStorage)
+		return
+	}
+	if remaining > 0 && (limit <= 0 || remaining < limit) {
+		limit = remaining
+	}
+
+	// 长度未知或与声明不符时，超出限制的读取会中止上传
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = http.MaxBytesReader(nil, resp.Body, limit)
+	}
+	opts := minio.PutObjectOptions{ContentType: objectContentType(key, resp.Header.Get("Content-Type")), PartSize: *partSize, NumThreads: *uploadThreads}
+	m, objectKey := resolveKey(key)
+	info, err := m.client().PutObject(ctx, m.Bucket, objectKey, body, resp.ContentLength, opts)
+	if err != nil {
+		reservation.settle(0)
+	}
+	if isTooLarge(err) {
+		http.Error(w, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Printf("URL 内容存储失败: %v", err)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	reservation.settle(info.Size)
+	w.Header().Set("ETag", `"`+info.ETag+`"`)
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"mime"
+	"path"
+	"strings"
+)
+
+var (
+	denyExts  = flag.String("deny-ext", "", "Comma separated extensions never served, e.g. .php,.exe")
+	denyTypes = flag.String("deny-type", "", "Comma separated content types never served, e.g. application/x-msdownload,video/*")
+	allowExts prefixMap
+)
+
+func init() {
+	flag.Var(&allowExts, "allow-ext", "The prefix=.ext1,.ext2 extensions only served under a prefix, can be repeated")
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func matchType(pattern, mediaType string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == mediaType
+}
+
+// 按扩展名和内容类型判断文件是否允许下载
+func fileAllowed(key, contentType string) bool {
+	ext := strings.ToLower(path.Ext(key))
+	for _, deny := range splitList(*denyExts) {
+		if ext == deny {
+			return false
+		}
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(contentType)
+	}
+	for _, deny := range splitList(*denyTypes) {
+		if matchType(deny, mediaType) {
+			return false
+		}
+	}
+
+	// 前缀白名单
+	if item, ok := allowExts.lookup(key); ok {
+		for _, allow := range splitList(item.Value) {
+			if ext == allow {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 可重复指定的字符串参数
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// 判断 key 是否位于列表中任一前缀之下
+func (s stringList) matchPrefix(key string) bool {
+	for _, prefix := range s {
+		if strings.HasPrefix(key, strings.TrimPrefix(prefix, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+type prefixValue struct {
+	Prefix string
+	Value  string
+}
+
+// 可重复指定的 prefix=value 参数
+type prefixMap []prefixValue
+
+func (m *prefixMap) String() string {
+	var items []string
+	for _, item := range *m {
+		items = append(items, item.Prefix+"="+item.Value)
+	}
+	return strings.Join(items, ",")
+}
+
+func (m *prefixMap) Set(value string) error {
+	prefix, v, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid value %q, expect prefix=value", value)
+	}
+	*m = append(*m, prefixValue{Prefix: strings.TrimPrefix(prefix, "/"), Value: v})
+	return nil
+}
+
+// 查找与 key 匹配的最长前缀
+func (m prefixMap) lookup(key string) (prefixValue, bool) {
+	var found prefixValue
+	ok := false
+	for _, item := range m {
+		if strings.HasPrefix(key, item.Prefix) && (!ok || len(item.Prefix) > len(found.Prefix)) {
+			found, ok = item, true
+		}
+	}
+	return found, ok
+}
+
+// 可重复指定的 key=value 参数
+type valueMap map[string]string
+
+func (m valueMap) String() string {
+	var items []string
+	for k, v := range m {
+		items = append(items, k+"="+v)
+	}
+	return strings.Join(items, ",")
+}
+
+func (m valueMap) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid value %q, expect key=value", value)
+	}
+	m[k] = v
+	return nil
+}
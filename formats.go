@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// 与 nginx autoindex_format json 相同的字段与结构
+func writeNginxJSON(w http.ResponseWriter, entries []DirEntry) {
+	var b strings.Builder
+	b.WriteString("[\n")
+	for i, entry := range entries {
+		name, _ := json.Marshal(entry.Name)
+		mtime := entry.ModTime
+		if mtime.IsZero() {
+			mtime = time.Unix(0, 0)
+		}
+		if entry.IsDir {
+			fmt.Fprintf(&b, `{ "name":%s, "type":"directory", "mtime":"%s" }`, name, mtime.UTC().Format(http.TimeFormat))
+		} else {
+			fmt.Fprintf(&b, `{ "name":%s, "type":"file", "mtime":"%s", "size":%d }`, name, mtime.UTC().Format(http.TimeFormat), entry.Bytes)
+		}
+		if i < len(entries)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		log.Printf("响应写入失败: %v", err)
+	}
+}
+
+var indexStyle = flag.String("index-style", "html", "The style of HTML directory listings, html or apache for mod_autoindex compatible output")
+
+// 按 Apache 的 ?C=N|M|S|D;O=A|D 参数排序，未指定时保持原有顺序
+func sortApache(entries []DirEntry, column, order string) {
+	less := map[string]func(a, b DirEntry) bool{
+		"N": func(a, b DirEntry) bool { return naturalLess(a.Name, b.Name) },
+		"M": func(a, b DirEntry) bool { return a.ModTime.Before(b.ModTime) },
+		"S": func(a, b DirEntry) bool { return a.Bytes < b.Bytes },
+		"D": func(a, b DirEntry) bool { return false },
+	}[column]
+	if less == nil {
+		return
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if order == "D" {
+			return less(entries[j], entries[i])
+		}
+		return less(entries[i], entries[j])
+	})
+}
+
+// 与 Apache apr_strfsize 相同的 4 字符大小格式
+func apacheSize(size int64) string {
+	if size < 0 {
+		return "  - "
+	}
+	if size < 973 {
+		return fmt.Sprintf("%3d ", size)
+	}
+	units := "KMGTPE"
+	for i := 0; ; i++ {
+		remain := size & 1023
+		size >>= 10
+		if size >= 973 && i < len(units)-1 {
+			continue
+		}
+		if size < 9 || (size == 9 && remain < 973) {
+			remain = ((remain * 5) + 256) / 512
+			if remain >= 10 {
+				size++
+				remain = 0
+			}
+			return fmt.Sprintf("%d.%d%c", size, remain, units[i])
+		}
+		if remain >= 512 {
+			size++
+		}
+		return fmt.Sprintf("%3d%c", size, units[i])
+	}
+}
+
+// 按路径分段转义，保留分隔的斜杠
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// 相对链接，按路径分段转义
+func relativeHref(name string, isDir bool) string {
+	href := escapePath(name)
+	if first, _, _ := strings.Cut(href, "/"); strings.HasPrefix(href, ".") || strings.Contains(first, ":") {
+		href = "./" + href
+	}
+	if isDir {
+		href += "/"
+	}
+	return href
+}
+
+// 列表页使用相对链接，目录以斜杠结尾，便于 wget -r -np、lftp mirror 递归镜像
+func entryHref(entry DirEntry) string {
+	if entry.Name == ".." {
+		return "../"
+	}
+	return relativeHref(entry.Name, entry.IsDir)
+}
+
+// 与 Apache mod_autoindex FancyIndexing 相同的 <pre> 格式，供抓取 Apache 镜像的脚本使用
+func writeApacheIndex(w http.ResponseWriter, r *http.Request, listing *Listing, showParent bool) {
+	// Apache 的排序链接用分号分隔参数（?C=N;O=D），只在这里按分号解析
+	query, _ := url.ParseQuery(strings.ReplaceAll(r.URL.RawQuery, ";", "&"))
+	column, order := query.Get("C"), query.Get("O")
+	entries := slices.Clone(listing.Entries)
+	sortApache(entries, column, order)
+
+	nameWidth := 23
+	for _, entry := range entries {
+		n := utf8.RuneCountInString(entry.Name)
+		if entry.IsDir {
+			n++
+		}
+		nameWidth = max(nameWidth, n)
+	}
+	header := func(c, title string) string {
+		o := "A"
+		if c == column && order != "D" {
+			o = "D"
+		}
+		return `<a href="?C=` + c + `;O=` + o + `">` + title + `</a>`
+	}
+
+	var b strings.Builder
+	title := html.EscapeString(listing.Path)
+	b.WriteString("<!DOCTYPE HTML PUBLIC \"-//W3C//DTD HTML 3.2 Final//EN\">\n<html>\n <head>\n  <title>Index of " + title + "</title>\n </head>\n <body>\n")
+	b.WriteString("<h1>Index of " + title + "</h1>\n")
+	b.WriteString("<pre>" + header("N", "Name") + strings.Repeat(" ", nameWidth-3) + header("M", "Last modified") + "      " + header("S", "Size") + "  " + header("D", "Description") + "<hr>")
+	if showParent {
+		b.WriteString(`<a href="../">Parent Directory</a>` + strings.Repeat(" ", max(nameWidth-15, 1)) + strings.Repeat(" ", 16) + "  " + apacheSize(-1) + "  \n")
+	}
+	for _, entry := range entries {
+		name := entry.Name
+		size := apacheSize(entry.Bytes)
+		if entry.IsDir {
+			name += "/"
+			size = apacheSize(-1)
+		}
+		mtime := strings.Repeat(" ", 16)
+		if !entry.ModTime.IsZero() {
+			mtime = entry.ModTime.Local().Format("2006-01-02 15:04")
+		}
+		pad := strings.Repeat(" ", nameWidth-utf8.RuneCountInString(name)+1)
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>%s%s  %s  \n", html.EscapeString(relativeHref(entry.Name, entry.IsDir)), html.EscapeString(name), pad, mtime, size)
+	}
+	b.WriteString("<hr></pre>\n</body></html>\n")
+
+	w.Header().Set("Content-Type", "text/html;charset=UTF-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		log.Printf("响应写入失败: %v", err)
+	}
+}
+
+var textForCLI = flag.Bool("text-for-cli", true, "Return plain-text listings to curl and clients accepting only text/plain")
+
+// curl 或只接受 text/plain 的客户端；wget 递归镜像需要解析 HTML 中的链接，仍返回 HTML
+func wantsText(w http.ResponseWriter, r *http.Request) bool {
+	if !*textForCLI {
+		return false
+	}
+	w.Header().Add("Vary", "User-Agent, Accept")
+	agent := strings.ToLower(r.UserAgent())
+	if strings.HasPrefix(agent, "curl/") {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "text/plain") && !strings.Contains(accept, "text/html")
+}
+
+// 每行一个名称，目录以斜杠结尾；?sizes=1 时附加以制表符分隔的字节数
+func writeTextListing(w http.ResponseWriter, r *http.Request, entries []DirEntry) {
+	sizes := r.URL.Query().Get("sizes") == "1"
+	var b strings.Builder
+	for _, entry := range entries {
+		b.WriteString(entry.Name)
+		if entry.IsDir {
+			b.WriteString("/")
+		} else if sizes {
+			fmt.Fprintf(&b, "\t%d", entry.Bytes)
+		}
+		b.WriteString("\n")
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := w.Write([]byte(b.String())); err != nil {
+		log.Printf("响应写入失败: %v", err)
+	}
+}
@@ -0,0 +1,732 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	backendKind = flag.String("backend", "s3", "The kind of backend, s3 or fs to serve a local directory without oss")
+	fsRoot      = flag.String("root", ".", "The directory served when -backend is fs, each bucket is a subdirectory when mounting several")
+)
+
+// 本地目录后端：在进程内提供一个 S3 兼容接口的最小子集，不监听任何端口，
+// 其余代码仍通过 MinIO 客户端访问，处理逻辑、模板与 API 完全一致
+type fsServer struct {
+	root      string
+	perBucket bool
+	uploads   string // 分片上传的临时目录
+}
+
+// 创建本地目录后端，MinIO 客户端的请求直接交给 fsServer 处理
+func startFSBackend() (*backendClient, error) {
+	root, err := filepath.Abs(*fsRoot)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	uploads, err := os.MkdirTemp("", "bucket2http-uploads-")
+	if err != nil {
+		return nil, err
+	}
+	s := &fsServer{root: root, perBucket: len(mountBuckets) > 0 || *allBuckets, uploads: uploads}
+	return newLocalBackend(handlerTransport{s})
+}
+
+// 在进程内调用 handler 的 RoundTripper，响应体经由管道流式返回
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, pw := io.Pipe()
+	rw := &pipeResponseWriter{header: http.Header{}, body: pw, ready: make(chan struct{})}
+	inner := req.Clone(req.Context())
+	inner.RequestURI = req.URL.RequestURI()
+	inner.RemoteAddr = "127.0.0.1:0"
+	if inner.Body == nil {
+		inner.Body = http.NoBody
+	}
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				rw.WriteHeader(http.StatusInternalServerError)
+				pw.CloseWithError(fmt.Errorf("local backend panic: %v", v))
+			}
+			rw.WriteHeader(http.StatusOK)
+			pw.Close()
+			inner.Body.Close()
+		}()
+		t.handler.ServeHTTP(rw, inner)
+	}()
+
+	select {
+	case <-rw.ready:
+	case <-req.Context().Done():
+		body.Close()
+		return nil, req.Context().Err()
+	}
+	contentLength := int64(-1)
+	if v, err := strconv.ParseInt(rw.sent.Get("Content-Length"), 10, 64); err == nil {
+		contentLength = v
+	}
+	var respBody io.ReadCloser = body
+	if req.Method == http.MethodHead {
+		body.Close()
+		respBody = http.NoBody
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", rw.status, http.StatusText(rw.status)),
+		StatusCode:    rw.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rw.sent,
+		Body:          respBody,
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+type pipeResponseWriter struct {
+	header http.Header
+	sent   http.Header // 写出时的响应头副本
+	body   *io.PipeWriter
+	status int
+	ready  chan struct{} // 响应头已写出
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status, w.sent = status, w.header.Clone()
+	close(w.ready)
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string
+	Message  string
+	Resource string
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	writeXML(w, status, s3Error{Code: code, Message: http.StatusText(status), Resource: r.URL.Path})
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func (s *fsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucketName == "" {
+		s.listBuckets(w, r)
+		return
+	}
+	dir, ok := s.bucketDir(bucketName)
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	if key == "" {
+		s.serveBucket(w, r, bucketName, dir)
+		return
+	}
+	file, ok := fsPath(dir, key)
+	if !ok {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		s.createUpload(w, r, bucketName, key)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		s.completeUpload(w, r, bucketName, key, file)
+	case r.Method == http.MethodPut && q.Has("uploadId"):
+		s.uploadPart(w, r)
+	case r.Method == http.MethodDelete && q.Has("uploadId"):
+		s.abortUpload(w, r)
+	case q.Has("tagging") && r.Method == http.MethodGet:
+		if _, err := os.Stat(file); err != nil {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"Tagging"`
+			TagSet  struct{}
+		}{})
+	case len(q) > 0 && !q.Has("versionId"):
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.getObject(w, r, key, file)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		s.copyObject(w, r, file)
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, key, file)
+	case r.Method == http.MethodDelete:
+		s.removeFile(dir, file)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// 只挂载一个存储桶时直接使用根目录，否则每个存储桶对应一个子目录
+func (s *fsServer) bucketDir(name string) (string, bool) {
+	if !s.perBucket {
+		return s.root, true
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	dir := filepath.Join(s.root, name)
+	info, err := os.Stat(dir)
+	return dir, err == nil && info.IsDir()
+}
+
+// 拒绝包含 .. 等无法安全映射到文件的 key
+func fsPath(dir, key string) (string, bool) {
+	clean := strings.TrimSuffix(key, "/")
+	if clean == "" || path.Clean("/"+clean) != "/"+clean || strings.Contains(clean, `\`) {
+		return "", false
+	}
+	return filepath.Join(dir, filepath.FromSlash(key)), true
+}
+
+// 由大小与修改时间生成 ETag，文件变化时随之改变
+func fsETag(info fs.FileInfo) string {
+	sum := md5.Sum([]byte(strconv.FormatInt(info.Size(), 10) + "-" + strconv.FormatInt(info.ModTime().UnixNano(), 10)))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func s3Time(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func (s *fsServer) listBuckets(w http.ResponseWriter, r *http.Request) {
+	type bucketInfo struct {
+		Name         string
+		CreationDate string
+	}
+	var result struct {
+		XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+		Xmlns   string       `xml:"xmlns,attr"`
+		Buckets []bucketInfo `xml:"Buckets>Bucket"`
+	}
+	result.Xmlns = s3Namespace
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && entry.IsDir() {
+			result.Buckets = append(result.Buckets, bucketInfo{entry.Name(), s3Time(info.ModTime())})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+func (s *fsServer) serveBucket(w http.ResponseWriter, r *http.Request, bucketName, dir string) {
+	q := r.URL.Query()
+	switch {
+	case r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPost && q.Has("delete"):
+		s.removeFiles(w, r, dir)
+	case r.Method != http.MethodGet:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	case q.Has("location"):
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"LocationConstraint"`
+			Xmlns   string   `xml:"xmlns,attr"`
+		}{Xmlns: s3Namespace})
+	case q.Has("versioning"):
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"VersioningConfiguration"`
+			Xmlns   string   `xml:"xmlns,attr"`
+		}{Xmlns: s3Namespace})
+	case q.Get("list-type") == "2":
+		s.listObjects(w, r, bucketName, dir)
+	default:
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+type fsObject struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type fsPrefix struct {
+	Prefix string
+}
+
+func (s *fsServer) listObjects(w http.ResponseWriter, r *http.Request, bucketName, dir string) {
+	q := r.URL.Query()
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	if delimiter != "" && delimiter != "/" {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+		return
+	}
+	maxKeys := 1000
+	if v, err := strconv.Atoi(q.Get("max-keys")); err == nil && v > 0 && v < maxKeys {
+		maxKeys = v
+	}
+	after := max(q.Get("start-after"), q.Get("continuation-token"))
+
+	objects, prefixes, err := listFiles(dir, prefix, delimiter)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+
+	var result struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Xmlns                 string   `xml:"xmlns,attr"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		MaxKeys               int
+		KeyCount              int
+		IsTruncated           bool
+		NextContinuationToken string     `xml:",omitempty"`
+		Contents              []fsObject `xml:",omitempty"`
+		CommonPrefixes        []fsPrefix `xml:",omitempty"`
+	}
+	result.Xmlns, result.Name, result.Prefix, result.Delimiter, result.MaxKeys = s3Namespace, bucketName, prefix, delimiter, maxKeys
+
+	// 文件与子目录合并后按 key 排序分页
+	keys := make([]string, 0, len(objects)+len(prefixes))
+	for key := range objects {
+		keys = append(keys, key)
+	}
+	for key := range prefixes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if key <= after {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		if obj, ok := objects[key]; ok {
+			result.Contents = append(result.Contents, obj)
+		} else {
+			result.CommonPrefixes = append(result.CommonPrefixes, fsPrefix{key})
+		}
+		result.KeyCount++
+		result.NextContinuationToken = key
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// 列出 prefix 下的文件，delimiter 为 / 时只列出一层并将子目录作为公共前缀
+func listFiles(dir, prefix, delimiter string) (map[string]fsObject, map[string]bool, error) {
+	objects, prefixes := map[string]fsObject{}, map[string]bool{}
+	parent := prefix[:strings.LastIndex(prefix, "/")+1]
+	base, ok := fsPath(dir, parent)
+	if parent == "" {
+		base, ok = dir, true
+	}
+	if !ok {
+		return objects, prefixes, nil
+	}
+	addFile := func(key string, info fs.FileInfo) {
+		objects[key] = fsObject{Key: key, LastModified: s3Time(info.ModTime()), ETag: fsETag(info), Size: info.Size(), StorageClass: "STANDARD"}
+	}
+	// 空目录与 MKCOL 创建的目录相同，作为以斜杠结尾的零字节对象列出
+	addDir := func(key string, info fs.FileInfo) {
+		objects[key] = fsObject{Key: key, LastModified: s3Time(info.ModTime()), ETag: `"d41d8cd98f00b204e9800998ecf8427e"`, StorageClass: "STANDARD"}
+	}
+
+	if delimiter == "/" {
+		entries, err := os.ReadDir(base)
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			return objects, prefixes, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(entries) == 0 && parent != "" && parent == prefix {
+			if info, err := os.Stat(base); err == nil {
+				addDir(prefix, info)
+			}
+		}
+		for _, entry := range entries {
+			key := parent + entry.Name()
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			// 跟随符号链接
+			info, err := os.Stat(filepath.Join(base, entry.Name()))
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				prefixes[key+"/"] = true
+			} else if info.Mode().IsRegular() {
+				addFile(key, info)
+			}
+		}
+		return objects, prefixes, nil
+	}
+
+	err := filepath.WalkDir(base, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if p == base {
+				return fs.SkipAll
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		key := filepath.ToSlash(rel)
+		if entry.IsDir() {
+			key += "/"
+			if !strings.HasPrefix(key, prefix) {
+				return nil
+			}
+			if entries, err := os.ReadDir(p); err == nil && len(entries) == 0 {
+				if info, err := entry.Info(); err == nil {
+					addDir(key, info)
+				}
+			}
+			return nil
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		if info, err := os.Stat(p); err == nil && info.Mode().IsRegular() {
+			addFile(key, info)
+		}
+		return nil
+	})
+	return objects, prefixes, err
+}
+
+func (s *fsServer) getObject(w http.ResponseWriter, r *http.Request, key, file string) {
+	if v := r.URL.Query().Get("versionId"); v != "" && v != "null" {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchVersion")
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || strings.HasSuffix(key, "/") {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fsETag(info))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// 写入临时文件后改名，读取方不会看到写了一半的文件
+func writeFileAtomic(file string, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (s *fsServer) putObject(w http.ResponseWriter, r *http.Request, key, file string) {
+	// 以斜杠结尾的空对象视为创建目录
+	if strings.HasSuffix(key, "/") {
+		if err := os.MkdirAll(file, 0o755); err != nil {
+			writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+			return
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		return
+	}
+	if err := writeFileAtomic(file, requestPayload(r)); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	if info, err := os.Stat(file); err == nil {
+		w.Header().Set("ETag", fsETag(info))
+	}
+}
+
+func (s *fsServer) copyObject(w http.ResponseWriter, r *http.Request, file string) {
+	source, err := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	source, _, _ = strings.Cut(source, "?versionId=")
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	srcDir, ok := s.bucketDir(srcBucket)
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	srcFile, ok := fsPath(srcDir, srcKey)
+	if !ok {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	f, err := os.Open(srcFile)
+	if err != nil {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+	defer f.Close()
+	if err := writeFileAtomic(file, f); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName      xml.Name `xml:"CopyObjectResult"`
+		ETag         string
+		LastModified string
+	}{ETag: fsETag(info), LastModified: s3Time(info.ModTime())})
+}
+
+// 删除文件并清理变空的上级目录，目录本身不是对象
+func (s *fsServer) removeFile(dir, file string) {
+	os.Remove(file)
+	for parent := filepath.Dir(file); parent != dir && strings.HasPrefix(parent, dir); parent = filepath.Dir(parent) {
+		if os.Remove(parent) != nil {
+			break
+		}
+	}
+}
+
+func (s *fsServer) removeFiles(w http.ResponseWriter, r *http.Request, dir string) {
+	var req struct {
+		Quiet   bool
+		Objects []struct{ Key string } `xml:"Object"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var result struct {
+		XMLName xml.Name `xml:"DeleteResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Deleted []struct{ Key string }
+	}
+	result.Xmlns = s3Namespace
+	for _, obj := range req.Objects {
+		if file, ok := fsPath(dir, obj.Key); ok {
+			s.removeFile(dir, file)
+		}
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, struct{ Key string }{obj.Key})
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+}
+
+// 解码 minio-go 在非 TLS 连接上使用的 aws-chunked 分块签名格式
+func requestPayload(r *http.Request) io.Reader {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return r.Body
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		br := bufio.NewReader(r.Body)
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+			size, err := strconv.ParseInt(sizeHex, 16, 64)
+			if err != nil {
+				pw.CloseWithError(fmt.Errorf("invalid chunk size %q", sizeHex))
+				return
+			}
+			if size == 0 {
+				pw.Close()
+				return
+			}
+			if _, err := io.CopyN(pw, br, size); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := br.Discard(2); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+func (s *fsServer) uploadDir(id string) (string, bool) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", false
+	}
+	dir := filepath.Join(s.uploads, id)
+	info, err := os.Stat(dir)
+	return dir, err == nil && info.IsDir()
+}
+
+func (s *fsServer) createUpload(w http.ResponseWriter, r *http.Request, bucketName, key string) {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	if err := os.Mkdir(filepath.Join(s.uploads, id), 0o700); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Xmlns: s3Namespace, Bucket: bucketName, Key: key, UploadId: id})
+}
+
+func (s *fsServer) uploadPart(w http.ResponseWriter, r *http.Request) {
+	dir, ok := s.uploadDir(r.URL.Query().Get("uploadId"))
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+		return
+	}
+	part, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || part < 1 {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	file := filepath.Join(dir, strconv.Itoa(part))
+	if err := writeFileAtomic(file, requestPayload(r)); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	if info, err := os.Stat(file); err == nil {
+		w.Header().Set("ETag", fsETag(info))
+	}
+}
+
+func (s *fsServer) completeUpload(w http.ResponseWriter, r *http.Request, bucketName, key, file string) {
+	dir, ok := s.uploadDir(r.URL.Query().Get("uploadId"))
+	if !ok {
+		writeS3Error(w, r, http.StatusNotFound, "NoSuchUpload")
+		return
+	}
+	var req struct {
+		Parts []struct{ PartNumber int } `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+	var readers []io.Reader
+	for _, part := range req.Parts {
+		f, err := os.Open(filepath.Join(dir, strconv.Itoa(part.PartNumber)))
+		if err != nil {
+			writeS3Error(w, r, http.StatusBadRequest, "InvalidPart")
+			return
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	if err := writeFileAtomic(file, io.MultiReader(readers...)); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	os.RemoveAll(dir)
+	info, err := os.Stat(file)
+	if err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError")
+		return
+	}
+	writeXML(w, http.StatusOK, struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Xmlns   string   `xml:"xmlns,attr"`
+		Bucket  string
+		Key     string
+		ETag    string
+	}{Xmlns: s3Namespace, Bucket: bucketName, Key: key, ETag: fsETag(info)})
+}
+
+func (s *fsServer) abortUpload(w http.ResponseWriter, r *http.Request) {
+	if dir, ok := s.uploadDir(r.URL.Query().Get("uploadId")); ok {
+		os.RemoveAll(dir)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+)
+
+var galleryTileLimit = flag.Int64("gallery-tile-limit", 2<<20, "Images larger than this many bytes are shown as a placeholder in the gallery view and only loaded when opened, 0 means no limit")
+
+// 相册视图模板，缩略图按需加载，过大的图片显示占位块，点击后在灯箱中查看，方向键切换
+const galleryTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Gallery of {{.Path}}</title>
+    {{template "style"}}
+    <style>
+        .dirs {
+            margin-bottom: 12px;
+        }
+        .grid {
+            display: grid;
+            grid-template-columns: repeat(auto-fill, minmax(160px, 1fr));
+            gap: 8px;
+        }
+        .grid figure {
+            margin: 0;
+        }
+        .grid img, .grid .placeholder {
+            width: 100%;
+            height: 160px;
+            object-fit: cover;
+            background-color: #f8f9fa;
+            cursor: zoom-in;
+        }
+        .grid .placeholder {
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            color: #999;
+        }
+        .grid figcaption {
+            overflow: hidden;
+            white-space: nowrap;
+            text-overflow: ellipsis;
+            color: #666;
+        }
+        #lightbox {
+            position: fixed;
+            inset: 0;
+            display: flex;
+            align-items: center;
+            justify-content: center;
+            background-color: rgba(0, 0, 0, 0.85);
+            cursor: zoom-out;
+        }
+        #lightbox[hidden] {
+            display: none;
+        }
+        #lightbox img {
+            max-width: 95%;
+            max-height: 95%;
+        }
+    </style>
+</head>
+<body>
+    <h1>Gallery of {{.Path}}</h1>
+    <p><a href="?">List view</a></p>
+    {{with .Dirs}}<div class="dirs">{{range .}}<a href="{{.URL}}?view=gallery">{{.Name}}/</a> {{end}}</div>{{end}}
+    <div class="grid">
+        {{range $i, $e := .Images}}
+        <figure>
+            {{if or (le $.TileLimit 0) (le $e.Bytes $.TileLimit)}}<img src="{{$e.URL}}" data-src="{{$e.URL}}" alt="{{$e.Name}}" loading="lazy" onclick="show({{$i}})">
+            {{else}}<div class="placeholder" data-src="{{$e.URL}}" title="{{$e.Name}}" onclick="show({{$i}})">{{$e.Size}}</div>{{end}}
+            <figcaption><a href="{{$e.URL}}" title="{{$e.Name}}">{{$e.Name}}</a></figcaption>
+        </figure>
+        {{end}}
+    </div>
+    <div id="lightbox" hidden onclick="this.hidden = true"><img id="full" alt=""></div>
+    <script>
+        const images = Array.from(document.querySelectorAll('.grid [data-src]'));
+        const lightbox = document.getElementById('lightbox');
+        let current = 0;
+        function show(i) {
+            current = (i + images.length) % images.length;
+            document.getElementById('full').src = images[current].dataset.src;
+            lightbox.hidden = false;
+        }
+        document.addEventListener('keydown', e => {
+            if (lightbox.hidden) {
+                return;
+            }
+            if (e.key === 'Escape') {
+                lightbox.hidden = true;
+            } else if (e.key === 'ArrowRight') {
+                show(current + 1);
+            } else if (e.key === 'ArrowLeft') {
+                show(current - 1);
+            }
+        });
+    </script>
+</body>
+</html>`
+
+var galleryTmpl = newPageTemplate("gallery", galleryTemplate)
+
+type galleryPage struct {
+	Path      string
+	Dirs      []DirEntry
+	Images    []DirEntry
+	TileLimit int64 // 超过该大小的图片不直接作为缩略图加载
+}
+
+func isImage(entry DirEntry) bool {
+	return !entry.IsDir && strings.HasPrefix(entry.ContentType, "image/")
+}
+
+// 文件中过半是图片时在列表中提供相册视图
+func mostlyImages(entries []DirEntry) bool {
+	files, images := 0, 0
+	for _, entry := range entries {
+		if entry.IsDir {
+			continue
+		}
+		files++
+		if isImage(entry) {
+			images++
+		}
+	}
+	return images > 0 && images*2 >= files
+}
+
+func writeGalleryView(w http.ResponseWriter, listing *Listing) {
+	page := galleryPage{Path: listing.Path, TileLimit: *galleryTileLimit}
+	for _, entry := range listing.Entries {
+		if entry.IsDir {
+			page.Dirs = append(page.Dirs, entry)
+		} else if isImage(entry) {
+			page.Images = append(page.Images, entry)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := galleryTmpl.Execute(w, page); err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
@@ -4,10 +4,16 @@ go 1.24
 
 toolchain go1.24.0
 
-require github.com/minio/minio-go/v7 v7.0.87
+require (
+	github.com/alecthomas/chroma/v2 v2.15.0
+	github.com/minio/minio-go/v7 v7.0.87
+	golang.org/x/crypto v0.33.0
+	golang.org/x/net v0.35.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dlclark/regexp2 v1.11.4 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/go-ini/ini v1.67.0 // indirect
 	github.com/goccy/go-json v0.10.5 // indirect
@@ -18,8 +24,6 @@ require (
 	github.com/minio/md5-simd v1.1.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rs/xid v1.6.0 // indirect
-	golang.org/x/crypto v0.33.0 // indirect
-	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var (
+	homeDir      = flag.String("home-dir", "", "Confine each authenticated user to a home prefix like users/{user}/, disabled when empty")
+	homeReadOnly = flag.Bool("home-read-only", false, "Only allow browsing the home prefix, writes are refused even with -write")
+)
+
+// 用户的目录前缀，用户名不能包含斜杠
+func homePath(user string) string {
+	if user == "" || strings.ContainsAny(user, "/\\") || user == "." || user == ".." {
+		return ""
+	}
+	home := strings.TrimPrefix(strings.ReplaceAll(*homeDir, "{user}", user), "/")
+	if !strings.HasSuffix(home, "/") {
+		home += "/"
+	}
+	return home
+}
+
+// 请求的是当前用户的目录本身
+func isHome(r *http.Request, prefix string) bool {
+	return *homeDir != "" && prefix != "" && prefix == homePath(authenticatedUser(r))
+}
+
+// 不写响应，只判断 key 是否位于当前用户的目录中
+func inHome(r *http.Request, key string) bool {
+	if *homeDir == "" {
+		return true
+	}
+	home := homePath(authenticatedUser(r))
+	return home != "" && strings.HasPrefix(key, home)
+}
+
+// 只允许访问自己的目录，访问上级目录时重定向到用户目录
+func checkHome(w http.ResponseWriter, r *http.Request, key string, write bool) bool {
+	if *homeDir == "" {
+		return true
+	}
+	user := authenticatedUser(r)
+	if user == "" {
+		requireAuth(w, r)
+		return false
+	}
+	home := homePath(user)
+	if home == "" {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return false
+	}
+	if strings.HasPrefix(key, home) {
+		if write && *homeReadOnly {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+	if !write && (key == "" || strings.HasSuffix(key, "/")) && strings.HasPrefix(home, key) && r.URL.Path == "/"+key {
+		// 目标因用户而异，不能使用永久重定向
+		http.Redirect(w, r, keyURL(home), http.StatusFound)
+		return false
+	}
+	http.Error(w, "403 Forbidden", http.StatusForbidden)
+	return false
+}
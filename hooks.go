@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// 请求钩子，返回 false 表示已自行写出响应，终止后续处理
+type requestHook func(w http.ResponseWriter, r *http.Request) bool
+
+// 响应完成后的钩子，用于审计、统计等
+type responseHook func(r *http.Request, resp *ResponseInfo)
+
+type ResponseInfo struct {
+	Status    int
+	Bytes     int64
+	FirstByte time.Duration // 开始写出响应头的耗时
+	Duration  time.Duration
+}
+
+// 集成方可在同一 package 的其他文件中通过 init() 注册钩子，无需修改处理逻辑
+var (
+	preAuthHooks      []requestHook
+	preBackendHooks   []requestHook
+	postResponseHooks []responseHook
+)
+
+// 在认证之前执行，可用于添加响应头或实现自定义认证方式
+func onPreAuth(hook requestHook) {
+	preAuthHooks = append(preAuthHooks, hook)
+}
+
+// 在认证与访问规则检查通过后、访问后端之前执行
+func onPreBackend(hook requestHook) {
+	preBackendHooks = append(preBackendHooks, hook)
+}
+
+// 在响应写出之后执行
+func onPostResponse(hook responseHook) {
+	postResponseHooks = append(postResponseHooks, hook)
+}
+
+func runHooks(hooks []requestHook, w http.ResponseWriter, r *http.Request) bool {
+	for _, hook := range hooks {
+		if !hook(w, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// 记录状态码与写出字节数，start 非零时同时记录首字节耗时
+type responseRecorder struct {
+	http.ResponseWriter
+	status    int
+	bytes     int64
+	start     time.Time
+	firstByte time.Duration
+}
+
+func (rec *responseRecorder) setStatus(status int) {
+	if rec.status != 0 {
+		return
+	}
+	rec.status = status
+	if !rec.start.IsZero() {
+		rec.firstByte = time.Since(rec.start)
+	}
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.setStatus(status)
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	rec.setStatus(http.StatusOK)
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// 在所有路由外层执行 pre-auth 与 post-response 钩子
+func withHooks(next http.Handler) http.Handler {
+	if len(preAuthHooks) == 0 && len(postResponseHooks) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, start: start}
+		if runHooks(preAuthHooks, rec, r) {
+			next.ServeHTTP(rec, r)
+		}
+		rec.setStatus(http.StatusOK)
+		info := &ResponseInfo{Status: rec.status, Bytes: rec.bytes, FirstByte: rec.firstByte, Duration: time.Since(start)}
+		for _, hook := range postResponseHooks {
+			hook(r, info)
+		}
+	})
+}
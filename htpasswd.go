@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var htpasswdPath = flag.String("htpasswd", "", "The Apache htpasswd file of users allowed to write, reloaded on change")
+
+// htpasswd 文件中的用户，文件修改后自动重新加载
+type htpasswdUsers struct {
+	mu      sync.RWMutex
+	users   map[string]string
+	modTime time.Time
+}
+
+var htpasswd = &htpasswdUsers{}
+
+func (h *htpasswdUsers) load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	h.mu.RLock()
+	unchanged := info.ModTime().Equal(h.modTime)
+	h.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	users := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if name, hash, ok := strings.Cut(line, ":"); ok {
+			users[name] = hash
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.users = users
+	h.modTime = info.ModTime()
+	h.mu.Unlock()
+	log.Printf("htpasswd 已加载: %d 个用户", len(users))
+	return nil
+}
+
+// 定期检查文件是否修改
+func (h *htpasswdUsers) watch(path string, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := h.load(path); err != nil {
+			log.Printf("htpasswd 加载失败: %v", err)
+		}
+	}
+}
+
+func (h *htpasswdUsers) has(name string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.users[name]
+	return ok
+}
+
+func (h *htpasswdUsers) verify(name, password string) bool {
+	h.mu.RLock()
+	hash, ok := h.users[name]
+	h.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(hash, "$2y$"), strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, "$apr1$"), "$")
+		return subtle.ConstantTimeCompare([]byte(apr1Crypt(password, salt)), []byte(hash)) == 1
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		return subtle.ConstantTimeCompare([]byte("{SHA}"+base64.StdEncoding.EncodeToString(sum[:])), []byte(hash)) == 1
+	}
+	return false
+}
+
+// Apache 的 MD5 crypt 变体
+func apr1Crypt(password, salt string) string {
+	const magic = "$apr1$"
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw := []byte(password)
+
+	alt := md5.Sum([]byte(password + salt + password))
+	h := md5.New()
+	h.Write([]byte(password + magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		h.Write(alt[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	final := h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(final)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(final)
+		} else {
+			h.Write(pw)
+		}
+		final = h.Sum(nil)
+	}
+
+	var buf []byte
+	encode := func(a, b, c byte, n int) {
+		v := uint(a)<<16 | uint(b)<<8 | uint(c)
+		for ; n > 0; n-- {
+			buf = append(buf, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+	encode(final[0], final[6], final[12], 4)
+	encode(final[1], final[7], final[13], 4)
+	encode(final[2], final[8], final[14], 4)
+	encode(final[3], final[9], final[15], 4)
+	encode(final[4], final[10], final[5], 4)
+	encode(0, 0, final[11], 2)
+	return magic + salt + "$" + string(buf)
+}
+
+func startHtpasswd() {
+	if *htpasswdPath == "" {
+		return
+	}
+	if err := htpasswd.load(*htpasswdPath); err != nil {
+		log.Fatal("htpasswd 加载失败: ", err)
+	}
+	go htpasswd.watch(*htpasswdPath, 5*time.Second)
+}
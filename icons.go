@@ -0,0 +1,108 @@
+package main
+
+import (
+	"embed"
+	"encoding/base64"
+	"flag"
+	"html/template"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"strings"
+)
+
+//go:embed icons/*.svg
+var embeddedIcons embed.FS
+
+var (
+	iconDir   = flag.String("icon-dir", "", "The directory of <type>.svg or <type>.png icons overriding or adding to the built-in icon set")
+	iconTypes = valueMap{}
+)
+
+func init() {
+	flag.Var(iconTypes, "icon-type", "The ext=type mapping of a file extension to an icon type, can be repeated")
+}
+
+// 扩展名对应的图标类型，优先于按 MIME 类型判断
+var extIconTypes = map[string]string{
+	".zip": "archive", ".tar": "archive", ".gz": "archive", ".tgz": "archive", ".bz2": "archive",
+	".xz": "archive", ".zst": "archive", ".7z": "archive", ".rar": "archive",
+	".iso": "iso", ".img": "iso", ".qcow2": "iso", ".vmdk": "iso",
+	".deb": "package", ".rpm": "package", ".apk": "package", ".msi": "package", ".dmg": "package", ".whl": "package", ".jar": "package",
+	".pdf": "pdf",
+	".go":  "code", ".c": "code", ".h": "code", ".cpp": "code", ".rs": "code", ".py": "code", ".js": "code", ".ts": "code",
+	".java": "code", ".sh": "code", ".rb": "code", ".php": "code", ".json": "code", ".yaml": "code", ".yml": "code",
+	".toml": "code", ".xml": "code", ".html": "code", ".css": "code",
+	".txt": "text", ".md": "text", ".log": "text", ".csv": "text", ".asc": "text", ".sig": "text",
+	".sha256": "text", ".sha512": "text", ".md5": "text",
+}
+
+// MIME 主类型对应的图标类型
+var mimeIconTypes = map[string]string{
+	"image": "image",
+	"audio": "audio",
+	"video": "video",
+	"text":  "text",
+}
+
+var icons map[string]template.HTML
+
+// 加载内置图标，再用 -icon-dir 中的同名文件覆盖
+func loadIcons() {
+	icons = map[string]template.HTML{}
+	addIcons(embeddedIcons, "icons")
+	if *iconDir != "" {
+		addIcons(os.DirFS(*iconDir), ".")
+	}
+}
+
+func addIcons(fsys fs.FS, dir string) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		log.Fatal("图标目录读取失败: ", err)
+	}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".svg" && ext != ".png") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			log.Fatal("图标读取失败: ", err)
+		}
+		kind := strings.TrimSuffix(entry.Name(), ext)
+		src := "data:" + mime.TypeByExtension(ext) + ";base64," + base64.StdEncoding.EncodeToString(data)
+		icons[kind] = template.HTML(`<img src="` + src + `" class="icon" alt="[` + strings.ToUpper(kind) + `]">`)
+	}
+}
+
+// 根据扩展名与 MIME 类型判断图标类型
+func iconType(key, contentType string) string {
+	if strings.HasSuffix(key, "/") {
+		return "dir"
+	}
+	ext := strings.ToLower(path.Ext(key))
+	if kind, ok := iconTypes[ext]; ok {
+		return kind
+	}
+	if kind, ok := extIconTypes[ext]; ok {
+		return kind
+	}
+	for _, t := range []string{contentType, mime.TypeByExtension(ext)} {
+		major, _, _ := strings.Cut(t, "/")
+		if kind, ok := mimeIconTypes[major]; ok {
+			return kind
+		}
+	}
+	return "file"
+}
+
+// 获取图标，未知类型使用通用文件图标
+func getFileIcon(kind string) template.HTML {
+	if icon, ok := icons[kind]; ok {
+		return icon
+	}
+	return icons["file"]
+}
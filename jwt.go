@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	jwksURL      = flag.String("jwks-url", "", "The JWKS URL of the SSO provider, bearer JWTs signed by its keys are accepted when set")
+	jwtAudience  = flag.String("jwt-audience", "", "The audience a JWT must be issued for, not checked when empty")
+	jwtIssuer    = flag.String("jwt-issuer", "", "The issuer a JWT must come from, not checked when empty")
+	jwtUserClaim = flag.String("jwt-user-claim", "sub", "The JWT claim used as the user name in access rules, logs and quotas")
+	jwksCacheTTL = flag.Duration("jwks-cache-ttl", time.Hour, "How long the JWKS is cached, unknown key IDs trigger an earlier refresh")
+	jwtGrants    prefixMap
+)
+
+func init() {
+	flag.Var(&jwtGrants, "jwt-grant", "The permission given to JWTs as prefix=read|write[:claim=value], to every valid JWT without a claim, JWT users can only write where granted, can be repeated")
+}
+
+// 签名公钥缓存，过期或遇到未知 kid 时刷新（至多每分钟一次），获取期间不持有锁
+var jwks struct {
+	sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time
+	attempted time.Time
+	loading   chan struct{}
+}
+
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+// 校验 -jwt-grant 的权限
+func startJWT() {
+	for _, item := range jwtGrants {
+		mode, _, _ := strings.Cut(item.Value, ":")
+		if mode != "read" && mode != "write" {
+			log.Fatalf("JWT 授权配置错误: %s=%s", item.Prefix, item.Value)
+		}
+	}
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func fetchJWKS() (map[string]crypto.PublicKey, error) {
+	resp, err := jwksClient.Get(*jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS returned %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		x, err := decode(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("unsupported OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func jwksKey(kid string) (crypto.PublicKey, error) {
+	jwks.Lock()
+	key, ok := jwks.keys[kid]
+	if ok && time.Since(jwks.fetched) <= *jwksCacheTTL {
+		jwks.Unlock()
+		return key, nil
+	}
+	loading := jwks.loading
+	if loading == nil && time.Since(jwks.attempted) >= time.Minute {
+		loading = make(chan struct{})
+		jwks.loading, jwks.attempted = loading, time.Now()
+		go loadJWKS(loading)
+	}
+	jwks.Unlock()
+
+	// 刷新期间与获取失败时继续使用过期的公钥
+	if ok {
+		return key, nil
+	}
+	if loading != nil {
+		<-loading
+		jwks.Lock()
+		key, ok = jwks.keys[kid]
+		jwks.Unlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return key, nil
+}
+
+func loadJWKS(done chan struct{}) {
+	keys, err := fetchJWKS()
+	jwks.Lock()
+	if err != nil {
+		log.Printf("JWKS 获取失败: %v", err)
+	} else {
+		jwks.keys, jwks.fetched = keys, time.Now()
+	}
+	jwks.loading = nil
+	jwks.Unlock()
+	close(done)
+}
+
+type jwtClaims map[string]any
+
+// 校验签名、有效期、签发者与受众
+func parseJWT(token string) (jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwksKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); !ok || now >= exp {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return nil, errors.New("token not yet valid")
+	}
+	if *jwtIssuer != "" && claims["iss"] != *jwtIssuer {
+		return nil, errors.New("issuer mismatch")
+	}
+	if *jwtAudience != "" && !claims.has("aud", *jwtAudience) {
+		return nil, errors.New("audience mismatch")
+	}
+	return claims, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// 只接受非对称算法，拒绝 none 与 HS*
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var h hash.Hash
+	var hashID crypto.Hash
+	switch {
+	case strings.HasSuffix(alg, "256"):
+		h, hashID = sha256.New(), crypto.SHA256
+	case strings.HasSuffix(alg, "384"):
+		h, hashID = sha512.New384(), crypto.SHA384
+	case strings.HasSuffix(alg, "512"):
+		h, hashID = sha512.New(), crypto.SHA512
+	}
+	if alg == "EdDSA" {
+		if key, ok := key.(ed25519.PublicKey); ok && ed25519.Verify(key, []byte(signed), signature) {
+			return nil
+		}
+		return errors.New("invalid signature")
+	}
+	if h == nil {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch {
+	case strings.HasPrefix(alg, "RS"):
+		if key, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(key, hashID, digest, signature) == nil {
+			return nil
+		}
+	case strings.HasPrefix(alg, "PS"):
+		if key, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPSS(key, hashID, digest, signature, nil) == nil {
+			return nil
+		}
+	case strings.HasPrefix(alg, "ES"):
+		key, ok := key.(*ecdsa.PublicKey)
+		if ok && len(signature)%2 == 0 {
+			half := len(signature) / 2
+			r, s := new(big.Int).SetBytes(signature[:half]), new(big.Int).SetBytes(signature[half:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return errors.New("invalid signature")
+}
+
+// 声明的值为字符串或字符串数组（如 groups、aud）时判断是否包含 value
+func (c jwtClaims) has(name, value string) bool {
+	switch v := c[name].(type) {
+	case []any:
+		for _, item := range v {
+			if fmt.Sprint(item) == value {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	default:
+		return fmt.Sprint(v) == value
+	}
+}
+
+func (c jwtClaims) user() string {
+	if user, ok := c[*jwtUserClaim].(string); ok {
+		return user
+	}
+	return ""
+}
+
+type jwtContextKey struct{}
+
+// 每个请求只校验一次 Bearer JWT，结果保存在请求上下文中
+func withJWT(next http.Handler) http.Handler {
+	if *jwksURL == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), jwtContextKey{}, verifyBearer(r))))
+	})
+}
+
+func verifyBearer(r *http.Request) jwtClaims {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	claims, err := parseJWT(strings.TrimSpace(token))
+	if err != nil {
+		log.Printf("JWT 校验失败: %v", err)
+		return nil
+	}
+	return claims
+}
+
+// 请求中有效的 Bearer JWT，未配置 JWKS、没有或无效时返回 nil
+func requestClaims(r *http.Request) jwtClaims {
+	if *jwksURL == "" {
+		return nil
+	}
+	if claims, ok := r.Context().Value(jwtContextKey{}).(jwtClaims); ok {
+		return claims
+	}
+	return verifyBearer(r)
+}
+
+// key 上授予该 JWT 的权限：write、read 或空
+func (c jwtClaims) grant(key string) string {
+	mode := ""
+	for _, item := range jwtGrants {
+		if !strings.HasPrefix(key, item.Prefix) {
+			continue
+		}
+		// 没有声明条件时授予所有有效的 JWT
+		grantMode, condition, conditional := strings.Cut(item.Value, ":")
+		claim, value, _ := strings.Cut(condition, "=")
+		if conditional && !c.has(claim, value) {
+			continue
+		}
+		if grantMode == "write" {
+			return "write"
+		}
+		mode = grantMode
+	}
+	return mode
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	languageVariant = flag.String("language-variant", "", "The key of a language variant like {key}.{lang}, picked by Accept-Language when the base key is requested, disabled when empty")
+	defaultLanguage = flag.String("default-language", "", "The language variant served when none in Accept-Language exists")
+	variantMissTTL  = flag.Duration("language-variant-cache-ttl", time.Minute, "How long a missing language variant is remembered before oss is asked again")
+)
+
+const (
+	// 每个请求最多尝试的语言数，避免很长的 Accept-Language 触发大量查询
+	maxLanguageTags = 5
+	// 缓存的不存在的语言版本数量上限
+	maxVariantMisses = 10000
+)
+
+// 不存在的语言版本 key -> 过期时间
+var (
+	variantMisses   = map[string]time.Time{}
+	variantMissesMu sync.Mutex
+)
+
+func variantMissing(variant string) bool {
+	variantMissesMu.Lock()
+	defer variantMissesMu.Unlock()
+	expires, ok := variantMisses[variant]
+	return ok && time.Now().Before(expires)
+}
+
+func rememberVariantMiss(variant string) {
+	variantMissesMu.Lock()
+	defer variantMissesMu.Unlock()
+	now := time.Now()
+	if len(variantMisses) >= maxVariantMisses {
+		for k, expires := range variantMisses {
+			if !now.Before(expires) {
+				delete(variantMisses, k)
+			}
+		}
+		// 仍然已满时全部丢弃
+		if len(variantMisses) >= maxVariantMisses {
+			variantMisses = map[string]time.Time{}
+		}
+	}
+	variantMisses[variant] = now.Add(*variantMissTTL)
+}
+
+// 写入后该 key 或目录下的 key 可能成为某个语言版本
+func forgetVariantMiss(key string) {
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	variantMissesMu.Lock()
+	defer variantMissesMu.Unlock()
+	delete(variantMisses, key)
+	for k := range variantMisses {
+		if strings.HasPrefix(k, prefix) {
+			delete(variantMisses, k)
+		}
+	}
+}
+
+// 按 q 值从高到低排列的语言，zh-CN 之后补充 zh
+func acceptLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var items []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if tag == "" || tag == "*" || q <= 0 {
+			continue
+		}
+		items = append(items, weighted{tag, q})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+
+	var langs []string
+	for _, item := range items {
+		primary, _, _ := strings.Cut(item.tag, "-")
+		for _, lang := range []string{item.tag, primary} {
+			if !slices.Contains(langs, lang) {
+				langs = append(langs, lang)
+			}
+		}
+	}
+	return langs
+}
+
+// 选择存在的语言版本，没有时返回原 key 与空语言
+func pickLanguageVariant(r *http.Request, key string) (string, string) {
+	langs := acceptLanguages(r.Header.Get("Accept-Language"))
+	if len(langs) > maxLanguageTags {
+		langs = langs[:maxLanguageTags]
+	}
+	if *defaultLanguage != "" {
+		langs = append(langs, *defaultLanguage)
+	}
+	for _, lang := range langs {
+		// 语言标签只含字母、数字与连字符
+		if strings.Trim(lang, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			continue
+		}
+		variant := strings.NewReplacer("{key}", key, "{lang}", lang).Replace(*languageVariant)
+		if variantMissing(variant) {
+			continue
+		}
+		if fileExists(variant) {
+			return variant, lang
+		}
+		rememberVariantMiss(variant)
+	}
+	return key, ""
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	maxBodySize     = flag.Int64("max-body-size", 0, "The maximum request body size in bytes, 0 means unlimited")
+	maxUploadSizes  prefixMap
+	maxDownloadsIP  = flag.Int("max-downloads-per-ip", 0, "The maximum simultaneous downloads of one client IP, 0 means unlimited")
+	downloadsStatus = flag.Int("downloads-limit-status", http.StatusTooManyRequests, "The status code returned to downloads over -max-downloads-per-ip")
+	downloadsRetry  = flag.Duration("downloads-retry-after", 10*time.Second, "The Retry-After returned to downloads over -max-downloads-per-ip")
+)
+
+// 每个客户端 IP 正在进行的下载数
+var activeDownloads = struct {
+	sync.Mutex
+	count map[string]int
+}{count: map[string]int{}}
+
+func init() {
+	flag.Var(&maxUploadSizes, "max-upload-size", "The prefix=bytes maximum upload size under a prefix, can be repeated")
+}
+
+// 请求体大小上限：前缀配置优先于全局配置
+func bodyLimit(key string) int64 {
+	if item, ok := maxUploadSizes.lookup(key); ok {
+		limit, err := strconv.ParseInt(item.Value, 10, 64)
+		if err == nil {
+			return limit
+		}
+		log.Printf("上传大小限制配置错误: %v", err)
+	}
+	return *maxBodySize
+}
+
+// 限制请求体大小，声明的长度已超限时直接返回 413
+func limitBody(w http.ResponseWriter, r *http.Request, key string) bool {
+	limit := bodyLimit(key)
+	if limit <= 0 {
+		return true
+	}
+	if r.ContentLength > limit {
+		http.Error(w, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	return true
+}
+
+// 占用一个下载名额，超出限制时返回 false
+func acquireDownload(r *http.Request) (func(), bool) {
+	if *maxDownloadsIP <= 0 || r.Method == http.MethodHead {
+		return func() {}, true
+	}
+	ip := clientIP(r)
+	activeDownloads.Lock()
+	defer activeDownloads.Unlock()
+	if activeDownloads.count[ip] >= *maxDownloadsIP {
+		return nil, false
+	}
+	activeDownloads.count[ip]++
+	return func() {
+		activeDownloads.Lock()
+		defer activeDownloads.Unlock()
+		if activeDownloads.count[ip]--; activeDownloads.count[ip] <= 0 {
+			delete(activeDownloads.count, ip)
+		}
+	}, true
+}
+
+func rejectDownload(w http.ResponseWriter, r *http.Request) {
+	logSecurity(r, "rate-limited")
+	w.Header().Set("Retry-After", strconv.Itoa(int(downloadsRetry.Seconds())))
+	http.Error(w, strconv.Itoa(*downloadsStatus)+" "+http.StatusText(*downloadsStatus), *downloadsStatus)
+}
+
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// 可重复或以逗号分隔指定的监听地址，首次指定时替换默认值
+type addressList struct {
+	items []string
+	set   bool
+}
+
+var addresses = &addressList{items: []string{":80"}}
+
+func init() {
+	flag.Var(addresses, "address", "The endpoint of service, several can be given comma separated or by repeating the flag, an IPv4 or IPv6 literal such as 0.0.0.0:80 or [::]:80 only accepts that family")
+}
+
+func (a *addressList) String() string {
+	return strings.Join(a.items, ",")
+}
+
+func (a *addressList) Set(value string) error {
+	if !a.set {
+		a.items, a.set = nil, true
+	}
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			a.items = append(a.items, addr)
+		}
+	}
+	return nil
+}
+
+// 按地址字面量选择协议族，[::] 只监听 IPv6，可与 0.0.0.0 同时监听同一端口
+func listenNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// 在所有地址上提供服务，任一监听出错时返回错误，收到 SIGINT 或 SIGTERM 时优雅关闭后返回 nil
+func serve(handler http.Handler) error {
+	ctx, abort := context.WithCancel(context.Background())
+	defer abort()
+	server := &http.Server{
+		Handler:     withDrain(handler),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	if *tlsCert != "" {
+		config, err := tlsConfig()
+		if err != nil {
+			log.Fatal("TLS 配置失败: ", err)
+		}
+		server.TLSConfig = config
+	}
+	errs := make(chan error, len(addresses.items))
+	for _, addr := range addresses.items {
+		listener, err := net.Listen(listenNetwork(addr), addr)
+		if err != nil {
+			return err
+		}
+		if listener, err = newProxyListener(listener); err != nil {
+			return err
+		}
+		log.Println("服务启动在 " + addr + " 端口...")
+		go func() {
+			if *tlsCert != "" {
+				errs <- server.ServeTLS(listener, *tlsCert, *tlsKey)
+			} else {
+				errs <- server.Serve(listener)
+			}
+		}()
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		return err
+	case <-signals:
+	}
+	shutdown(server, abort)
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 对象锁定（WORM）状态，来自对象的响应头
+type ObjectLock struct {
+	Mode        string     `json:"mode,omitempty"` // GOVERNANCE 或 COMPLIANCE
+	RetainUntil *time.Time `json:"retainUntil,omitempty"`
+	LegalHold   bool       `json:"legalHold,omitempty"`
+}
+
+func objectLock(objInfo minio.ObjectInfo) *ObjectLock {
+	lock := &ObjectLock{
+		Mode:      objInfo.Metadata.Get("X-Amz-Object-Lock-Mode"),
+		LegalHold: objInfo.Metadata.Get("X-Amz-Object-Lock-Legal-Hold") == "ON",
+	}
+	if until, err := time.Parse(time.RFC3339, objInfo.Metadata.Get("X-Amz-Object-Lock-Retain-Until-Date")); err == nil {
+		lock.RetainUntil = &until
+	}
+	if lock.Mode == "" && lock.RetainUntil == nil && !lock.LegalHold {
+		return nil
+	}
+	return lock
+}
+
+// 当前是否禁止删除，返回原因
+func (l *ObjectLock) reason() string {
+	if l == nil {
+		return ""
+	}
+	if l.LegalHold {
+		return "under legal hold"
+	}
+	if l.RetainUntil != nil && l.RetainUntil.After(time.Now()) {
+		return fmt.Sprintf("retained in %s mode until %s", l.Mode, l.RetainUntil.UTC().Format(time.RFC3339))
+	}
+	return ""
+}
+
+// 检查待删除的对象，返回被锁定的对象及原因
+func lockedObjects(ctx context.Context, keys []string) ([]string, error) {
+	var locked []string
+	for _, key := range keys {
+		if strings.HasSuffix(key, "/") {
+			continue
+		}
+		m, objectKey := resolveKey(key)
+		objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{})
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				continue
+			}
+			return nil, err
+		}
+		if reason := objectLock(objInfo).reason(); reason != "" {
+			locked = append(locked, keyURL(key)+": "+reason)
+		}
+	}
+	return locked, nil
+}
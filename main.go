@@ -2,10 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"html/template"
-	"io"
 	"log"
 	"net/http"
 	"path"
@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 // HTML 目录列表模板
@@ -67,167 +66,562 @@ const dirListTemplate = `
         a:hover {
             text-decoration: underline;
         }
+        .toolbar {
+            margin-bottom: 10px;
+        }
+        .tag {
+            padding: 0 4px;
+            background-color: #f1f8ff;
+            border-radius: 3px;
+        }
+        .archived {
+            color: #999;
+            font-size: 11px;
+        }
+        .footer {
+            margin-top: 10px;
+            color: #666;
+        }
     </style>
 </head>
 <body>
     <h1>Index of {{.Path}}</h1>
+    {{if or .Manage .Zip}}
+    <div class="toolbar">
+        {{if .Manage}}
+        <input type="file" id="upload" multiple>
+        <button onclick="mkdir()">New Folder</button>
+        <button onclick="rename()">Rename</button>
+        <button onclick="remove()">Delete</button>
+        {{end}}
+        {{if .Zip}}<button onclick="downloadZip()">Download Selected</button>{{end}}
+    </div>
+    {{end}}
     <table>
-        <tr><th>Name</th><th>Size</th><th>Last Modified</th></tr>
-        {{range .Entries}}
+        <tr>{{if or .Manage .Zip}}<th></th>{{end}}{{range .Columns}}<th>{{.Title}}</th>{{end}}{{if .ShowTags}}<th>Tags</th>{{end}}</tr>
+        {{range .Entries}}{{$e := .}}
         <tr>
+            {{if or $.Manage $.Zip}}<td>{{if ne .Name ".."}}<input type="checkbox" name="select" value="{{.URL}}" data-name="{{.Name}}{{if .IsDir}}/{{end}}">{{end}}</td>{{end}}
+            {{range $.Columns}}
+            {{if eq .ID "name"}}
             <td>
-                {{.Icon}}
-                <a href="{{.URL}}" class="{{if .IsDir}}folder{{end}}">
-                    {{.Name}}{{if .IsDir}}/{{end}}
+                {{$e.Icon}}
+                <a href="{{entryHref $e}}" class="{{if $e.IsDir}}folder{{end}}">
+                    {{$e.Name}}{{if $e.IsDir}}/{{end}}
                 </a>
+                {{if $e.Archived}}<span class="archived">archived</span>{{end}}
             </td>
-            <td>{{.Size}}</td>
-            <td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td>
+            {{else if eq .ID "size"}}<td{{if not $e.IsDir}} title="{{$e.Bytes}} bytes"{{end}}>{{$e.Size}}</td>
+            {{else if eq .ID "mtime"}}{{if $.RelativeTime}}<td title="{{$e.ModTime.Format "2006-01-02 15:04:05"}}">{{if not $e.ModTime.IsZero}}{{relativeTime $e.ModTime}}{{end}}</td>{{else}}<td>{{$e.ModTime.Format "2006-01-02 15:04:05"}}</td>{{end}}
+            {{else if eq .ID "etag"}}<td>{{$e.ETag}}</td>
+            {{else if eq .ID "class"}}<td>{{$e.Class}}</td>
+            {{else if eq .ID "type"}}<td>{{$e.ContentType}}</td>
+            {{else if eq .ID "owner"}}<td>{{$e.Owner}}</td>
+            {{else if eq .ID "downloads"}}<td>{{if not $e.IsDir}}{{$e.Downloads}}{{end}}</td>
+            {{end}}
+            {{end}}
+            {{if $.ShowTags}}<td>{{range $k, $v := .Tags}}<span class="tag">{{$k}}={{$v}}</span> {{end}}</td>{{end}}
         </tr>
         {{end}}
     </table>
+    <div class="footer">{{.Files}} files, {{.Dirs}} directories, {{.TotalSize}} total{{if .Views}}<span id="views"></span>{{end}}</div>
+    {{if .Views}}
+    <script>
+        // 切换视图的链接带查询参数，由脚本生成，HTML 中不出现，避免被 wget、lftp 等镜像工具抓取
+        for (const [view, title] of [['tree', 'Tree view']{{if .Gallery}}, ['gallery', 'Gallery view']{{end}}]) {
+            const a = document.createElement('a');
+            a.href = '?view=' + view;
+            a.rel = 'nofollow';
+            a.textContent = title;
+            document.getElementById('views').append(' · ', a);
+        }
+    </script>
+    {{end}}
+    {{if or .Manage .Zip}}
+    <script>
+        function selected() {
+            return Array.from(document.querySelectorAll('input[name=select]:checked')).map(e => e.value);
+        }
+        function downloadZip() {
+            const names = Array.from(document.querySelectorAll('input[name=select]:checked')).map(e => ['name', e.dataset.name]);
+            if (names.length === 0) {
+                alert('Select items to download');
+                return;
+            }
+            location.href = '?' + new URLSearchParams([['zip', '']].concat(names));
+        }
+    </script>
+    {{end}}
+    {{if .Manage}}
+    <script>
+        const base = {{.Path}};
+        const csrf = {{.CSRF}};
+        async function send(method, url, options) {
+            options = Object.assign({}, options);
+            options.headers = Object.assign({'X-CSRF-Token': csrf}, options.headers);
+            const resp = await fetch(url, Object.assign({method: method}, options));
+            if (!resp.ok) {
+                alert(method + ' ' + url + ': ' + resp.status);
+            }
+        }
+        document.getElementById('upload').onchange = async e => {
+            for (const file of e.target.files) {
+                await send('PUT', base + encodeURIComponent(file.name), {body: file});
+            }
+            location.reload();
+        };
+        async function mkdir() {
+            const name = prompt('Folder name');
+            if (name) {
+                await send('MKCOL', base + encodeURIComponent(name) + '/');
+                location.reload();
+            }
+        }
+        async function rename() {
+            const items = selected();
+            if (items.length !== 1) {
+                alert('Select one item to rename');
+                return;
+            }
+            const name = prompt('New name');
+            if (name) {
+                const suffix = items[0].endsWith('/') ? '/' : '';
+                await send('MOVE', items[0], {headers: {Destination: encodeURI(base) + encodeURIComponent(name) + suffix}});
+                location.reload();
+            }
+        }
+        async function remove() {
+            const items = selected();
+            if (items.length === 0 || !confirm('Delete ' + items.length + ' item(s)?')) {
+                return;
+            }
+            for (const item of items) {
+                await send('DELETE', item);
+            }
+            location.reload();
+        }
+    </script>
+    {{end}}
 </body>
 </html>`
 
 var (
-	minioClient *minio.Client
-	address     = flag.String("address", ":80", "The endpoint of service")
-	bucket      = flag.String("bucket", "mirror", "The bucket of oss")
-	endpoint    = flag.String("endpoint", "192.168.31.12:9000", "The endpoint of oss")
-	accessKey   = flag.String("access-key", "bailexian", "The access key of oss")
-	secretKey   = flag.String("secret-key", "bailexian_kakoi", "The secret key of oss")
-	tmpl        = template.Must(template.New("dirlist").Parse(dirListTemplate))
+	defaultBackend *backendClient
+	bucket         = flag.String("bucket", "mirror", "The bucket of oss")
+	endpoint       = flag.String("endpoint", "192.168.31.12:9000", "The endpoint of oss")
+	accessKey      = flag.String("access-key", "bailexian", "The access key of oss")
+	secretKey      = flag.String("secret-key", "bailexian_kakoi", "The secret key of oss")
+	region         = flag.String("region", "", "The region of oss used for request signing, detected from the bucket when empty")
+	showClass      = flag.Bool("show-storage-class", false, "Show the storage class column in directory listings")
+	tmpl           = template.Must(template.New("dirlist").Funcs(template.FuncMap{"relativeTime": formatRelativeTime, "entryHref": entryHref}).Parse(dirListTemplate))
 )
 
+// 目录列表页模板的数据
+type listingPage struct {
+	Path         string
+	Entries      []DirEntry
+	Manage       bool
+	CSRF         string
+	Columns      columnList
+	RelativeTime bool
+	ShowTags     bool
+	Files        int
+	Dirs         int
+	TotalSize    string
+	Views        bool // 显示切换视图的链接，静态索引中不显示
+	Gallery      bool
+	Zip          bool
+}
+
 type DirEntry struct {
-	URL     string
-	Name    string
-	Size    string
-	ModTime time.Time
-	IsDir   bool
-	Icon    template.HTML
+	Key         string            `json:"-"`
+	URL         string            `json:"url"`
+	Name        string            `json:"name"`
+	Size        string            `json:"-"`
+	Bytes       int64             `json:"size"`
+	ModTime     time.Time         `json:"lastModified"`
+	IsDir       bool              `json:"isDir"`
+	ETag        string            `json:"etag,omitempty"`
+	Class       string            `json:"storageClass,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Owner       string            `json:"owner,omitempty"`
+	Downloads   int64             `json:"downloads,omitempty"`
+	Archived    bool              `json:"archived,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Icon        template.HTML     `json:"-"`
 }
 
 func main() {
 	// 初始化参数
 	flag.Parse()
-	// 初始化 MinIO 客户端
-	useSSL := false
-	client, err := minio.New(*endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(*accessKey, *secretKey, ""),
-		Secure: useSSL,
-	})
-	if err != nil {
-		log.Fatal("MinIO 连接失败: ", err)
+	loadIcons()
+	// 本地目录后端，供开发与测试使用
+	switch *backendKind {
+	case "s3":
+		// 初始化 MinIO 客户端
+		backend, err := newBackend(*endpoint, *accessKey, *secretKey, *region)
+		if err != nil {
+			log.Fatal("MinIO 连接失败: ", err)
+		}
+		defaultBackend = backend
+	case "fs":
+		backend, err := startFSBackend()
+		if err != nil {
+			log.Fatal("本地目录后端启动失败: ", err)
+		}
+		defaultBackend = backend
+		log.Printf("使用本地目录后端: %s", *fsRoot)
+	default:
+		log.Fatalf("后端类型无效: %s", *backendKind)
+	}
+
+	startHtpasswd()
+	startMimeTypes()
+	startSessions()
+	startJWT()
+	startArchive()
+	startAudit()
+	startAccessLog()
+	startSecurityLog()
+	startWebhook()
+	startBackend()
+	startCanary()
+	startCache()
+	if *indexObjectsOnce {
+		for !backendReady.Load() {
+			time.Sleep(time.Second)
+		}
+		if err := writeIndexObjects(context.Background()); err != nil {
+			log.Fatal("目录索引生成失败: ", err)
+		}
+		return
+	}
+	startIndexObjects()
+	startMaintenance()
+	startAdmin()
+
+	mux := newMux()
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", handleHealthz)
+	root.HandleFunc("/readyz", handleReadyz)
+	root.HandleFunc("/favicon.ico", handleFavicon)
+	app := withJWT(withUser(withHooks(withMaintenance(withCanonicalHost(withReadOnly(withReady(mux)))))))
+	root.Handle("/.well-known/", withWellKnown(app))
+	root.Handle("/", app)
+	if err := serve(root); err != nil {
+		log.Fatal(err)
 	}
-	minioClient = client
+}
 
-	http.HandleFunc("/", handler)
-	log.Println("服务启动在 " + *address + " 端口...")
-	log.Fatal(http.ListenAndServe(*address, nil))
+// 网关自身的路由，其余路径按对象 key 处理
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", handleSearch)
+	mux.HandleFunc("/feed.xml", handleFeed)
+	mux.HandleFunc("/logout", handleLogout)
+	mux.HandleFunc("/api/v1/", handleAPI)
+	mux.HandleFunc("/", handler)
+	return mux
 }
 
 func handler(w http.ResponseWriter, r *http.Request) {
+	// 路径改写规则
+	if !handleRewrite(w, r) {
+		return
+	}
+
 	requestPath := r.URL.Path
-	key := strings.TrimPrefix(requestPath, "/")
+	key := aliasKey(strings.TrimPrefix(requestPath, "/"))
+
+	// 多存储桶时根目录列出挂载点，挂载点之外的路径不存在
+	if multiMount() && findMount(key) == nil {
+		if m := findMount(key + "/"); m != nil && m.Path == key+"/" {
+			redirectCanonical(w, r, r.URL.EscapedPath()+"/")
+			return
+		}
+		if key != "" {
+			http.Error(w, "404 Not Found", http.StatusNotFound)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !listingAllowed("") {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+		if !checkHome(w, r, "", false) {
+			return
+		}
+		handleMountIndex(w, r)
+		return
+	}
+
+	// 写操作
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		auditWrite(w, r, key, func(w http.ResponseWriter, r *http.Request) {
+			serveWrite(w, r, key)
+		})
+		return
+	}
+
+	// 目录访问规则
+	if !checkAccess(w, r, key, false) || !runHooks(preBackendHooks, w, r) {
+		return
+	}
+
+	// 回收站，只读模式下无法恢复，不提供
+	if r.URL.Query().Has("trash") {
+		if !*writable || *readOnly {
+			http.Error(w, "404 Not Found", http.StatusNotFound)
+			return
+		}
+		// 已删除的文件只对已认证用户列出
+		if !checkAuth(w, r) || !checkUser(w, r) {
+			return
+		}
+		handleTrash(w, r, key)
+		return
+	}
+
+	// 版本历史
+	if r.URL.Query().Has("versions") {
+		handleVersions(w, r, key)
+		return
+	}
+
+	// 归档文件解冻状态
+	if r.URL.Query().Has("thaw") {
+		handleThawStatus(w, key)
+		return
+	}
+
+	// 目录校验和清单
+	if r.URL.Query().Has("sums") {
+		handleSums(w, r, key)
+		return
+	}
+
+	// S3 Select 查询
+	if r.URL.Query().Has("select") {
+		handleSelect(w, r, key)
+		return
+	}
+
+	// 打包下载选中的文件
+	if r.URL.Query().Has("zip") {
+		handleZip(w, r, key)
+		return
+	}
+
+	// 文本语法高亮预览
+	if r.URL.Query().Get("view") == "1" && handlePreview(w, r, key) {
+		return
+	}
 
 	// 尝试作为文件处理
-	if handleFile(w, key) {
+	start := time.Now()
+	rec := &responseRecorder{ResponseWriter: w}
+	if handleFile(rec, r, key) {
+		downloadCompleted(r, key, rec, start)
+		return
+	}
+
+	// 不存在的 .meta4 文件按需生成
+	if strings.HasSuffix(key, ".meta4") && handleMetalink(w, r, key) {
+		return
+	}
+
+	// 目录缺少结尾斜杠时重定向
+	if key != "" && !strings.HasSuffix(key, "/") {
+		if dirExists(key + "/") {
+			redirectCanonical(w, r, r.URL.EscapedPath()+"/")
+			return
+		}
+		// 转发到源站或从上游镜像回源
+		if handleOrigin(w, r, key) || handlePullThrough(w, r, key) {
+			return
+		}
+		http.Error(w, "404 Not Found", http.StatusNotFound)
 		return
 	}
 
 	// 尝试作为目录处理
-	if handleDirectory(w, key) {
+	if handleDirectory(w, r, key) {
+		return
+	}
+
+	// 文件带有多余的结尾斜杠时重定向
+	if key != "" && fileExists(strings.TrimSuffix(key, "/")) {
+		redirectCanonical(w, r, strings.TrimSuffix(r.URL.EscapedPath(), "/"))
 		return
 	}
 
 	// 未找到资源
+	if handleOrigin(w, r, key) {
+		return
+	}
 	http.Error(w, "404 Not Found", http.StatusNotFound)
 }
 
-func handleFile(w http.ResponseWriter, key string) bool {
-	// 检查文件是否存在
-	objInfo, err := minioClient.StatObject(context.Background(), *bucket, key, minio.StatObjectOptions{})
-	if objInfo.ContentType == "application/x-directory" {
-		return false
+func serveWrite(w http.ResponseWriter, r *http.Request, key string) {
+	if !*writable {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	if err != nil {
-		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
-			return false
-		}
-		log.Printf("文件检查失败: %v", err)
+	if !checkCSRF(w, r) || !checkAuth(w, r) || !checkTransferAuth(w, r) || !checkAccess(w, r, key, true) || !limitBody(w, r, key) || !checkQuota(w, r) {
+		return
+	}
+	if dest, ok := destinationKey(r); ok && !checkAccess(w, r, dest, true) {
+		return
+	}
+	if !runHooks(preBackendHooks, w, r) {
+		return
+	}
+	handleWrite(w, r, key)
+	// 写入、删除或移动后缓存失效
+	dropCached(key)
+	dropCachedPrefix(strings.TrimSuffix(key, "/") + "/")
+	expireDirSizes(key)
+	forgetVariantMiss(key)
+	if dest, ok := destinationKey(r); ok {
+		dropCached(dest)
+		dropCachedPrefix(strings.TrimSuffix(dest, "/") + "/")
+		expireDirSizes(dest)
+		forgetVariantMiss(dest)
+	}
+	// 访问规则可能被修改
+	if isAccessFile(key) || strings.HasSuffix(key, "/") {
+		resetAccessCache()
+	}
+}
+
+func handleFile(w http.ResponseWriter, r *http.Request, key string) bool {
+	if key == "" || strings.HasSuffix(key, "/") {
 		return false
 	}
+	// 指定历史版本
+	versionID := r.URL.Query().Get("versionId")
+
+	// 覆盖目录中的文件优先
+	if !*overlayBelow && serveOverlay(w, r, key) {
+		return true
+	}
+
+	// 按 Accept-Language 选择语言版本
+	objectKey, lang := key, ""
+	if *languageVariant != "" && versionID == "" {
+		w.Header().Add("Vary", "Accept-Language")
+		objectKey, lang = pickLanguageVariant(r, key)
+	}
 
-	// 获取文件内容
-	object, err := minioClient.GetObject(context.Background(), *bucket, key, minio.GetObjectOptions{})
+	// 一次请求同时获取文件信息和内容
+	open := openObject
+	if r.Method == http.MethodHead {
+		open = openObjectHead
+	}
+	object, objInfo, err := open(r.Context(), objectKey, versionID)
 	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey":
+			return *overlayBelow && serveOverlay(w, r, key)
+		case "InvalidObjectState":
+			// 归档存储中的文件，可能是选中的语言版本
+			handleArchived(w, r, objectKey)
+			return true
+		}
 		log.Printf("文件获取失败: %v", err)
-		return false
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return true
 	}
 	defer object.Close()
+	if objInfo.ContentType == "application/x-directory" {
+		return false
+	}
+	contentType := objectContentType(key, objInfo.ContentType)
+	if !fileAllowed(key, contentType) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return true
+	}
+
+	// 限制同一 IP 的并发下载数
+	release, ok := acquireDownload(r)
+	if !ok {
+		rejectDownload(w, r)
+		return true
+	}
+	defer release()
+
+	// 文本文件补充字符集
+	contentType, body := withCharset(contentType, object)
 
 	// 设置下载头
-	w.Header().Set("Content-Type", getContentType(key))
+	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", objInfo.Size))
+	if lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+	if objInfo.ETag != "" {
+		w.Header().Set("ETag", `"`+objInfo.ETag+`"`)
+	}
+
+	if r.Method == http.MethodHead {
+		return true
+	}
 
 	// 流式传输内容
-	if _, err := io.Copy(w, object); err != nil {
+	if _, err := copyBuffered(w, body); err != nil {
 		log.Printf("响应写入失败: %v", err)
 	}
 	return true
 }
 
-func handleDirectory(w http.ResponseWriter, prefix string) bool {
-	// 自动添加目录斜杠
-	if !strings.HasSuffix(prefix, "/") {
-		prefix += "/"
-	}
-	if prefix == "/" {
-		prefix = ""
-	}
+// 目录列表
+type Listing struct {
+	Path      string     `json:"path"`
+	Entries   []DirEntry `json:"entries"`
+	Files     int        `json:"files"`
+	Dirs      int        `json:"directories"`
+	TotalSize int64      `json:"totalSize"`
+}
 
-	// 列出目录内容
-	ch := minioClient.ListObjects(context.Background(), *bucket, minio.ListObjectsOptions{
+// 列出目录内容，递归模式下列出全部子孙对象，visible 为 false 的文件不出现；目录不存在时返回 nil
+func listDirectory(ctx context.Context, prefix string, recursive bool, visible func(key string) bool) (*Listing, error) {
+	ch := listObjects(ctx, minio.ListObjectsOptions{
 		Prefix:    prefix,
-		Recursive: false,
+		Recursive: recursive,
 	})
 
-	var entries []DirEntry
+	listing := &Listing{Path: keyURL(prefix), Entries: []DirEntry{}}
 	hasContent := false
 
-	// 添加父目录链接
-	if prefix != "" {
-		parent := path.Dir(strings.TrimSuffix(prefix, "/")) + "/"
-		entries = append(entries, DirEntry{
-			URL:     "/" + parent,
-			Name:    "..",
-			Size:    "-",
-			ModTime: time.Time{},
-			IsDir:   true,
-			Icon:    getFileIcon("dir"),
-		})
-	}
-
 	// 处理目录结果
 	for obj := range ch {
 		if obj.Err != nil {
-			log.Printf("目录列表错误: %v", obj.Err)
-			return false
+			return nil, obj.Err
 		}
 
 		hasContent = true
 
-		// 过滤当前目录
-		if obj.Key == prefix {
+		// 过滤当前目录和访问规则对象，递归时跳过禁止列出的子目录
+		if obj.Key == prefix || isAccessFile(obj.Key) || (recursive && !keyListable(obj.Key)) {
 			continue
 		}
 
-		if obj.StorageClass == "" {
+		// 递归模式下显示相对路径
+		name := path.Base(obj.Key)
+		if recursive {
+			name = strings.TrimSuffix(strings.TrimPrefix(obj.Key, prefix), "/")
+		}
+
+		if obj.StorageClass == "" || strings.HasSuffix(obj.Key, "/") {
 			// 处理子目录
-			entries = append(entries, DirEntry{
-				URL:     "/" + obj.Key,
-				Name:    path.Base(obj.Key),
+			listing.Dirs++
+			listing.Entries = append(listing.Entries, DirEntry{
+				Key:     obj.Key,
+				URL:     keyURL(obj.Key),
+				Name:    name,
 				Size:    "-",
 				ModTime: time.Time{},
 				IsDir:   true,
@@ -235,30 +629,168 @@ func handleDirectory(w http.ResponseWriter, prefix string) bool {
 			})
 		} else {
 			// 处理文件
-			entries = append(entries, DirEntry{
-				URL:     "/" + obj.Key,
-				Name:    path.Base(obj.Key),
-				Size:    formatSize(obj.Size),
-				ModTime: obj.LastModified,
-				IsDir:   false,
-				Icon:    getFileIcon("file"),
+			archived := isArchived(obj.StorageClass) && !restored(ctx, obj.Key)
+			if (archived && archiveMode(obj.Key) == "hide") || !visible(obj.Key) {
+				continue
+			}
+			listing.Files++
+			listing.TotalSize += obj.Size
+			listing.Entries = append(listing.Entries, DirEntry{
+				Key:         obj.Key,
+				URL:         keyURL(obj.Key),
+				Name:        name,
+				Size:        formatSize(obj.Size),
+				Bytes:       obj.Size,
+				ModTime:     obj.LastModified,
+				IsDir:       false,
+				ETag:        obj.ETag,
+				Class:       obj.StorageClass,
+				ContentType: objectContentType(obj.Key, obj.ContentType),
+				Owner:       obj.Owner.DisplayName,
+				Archived:    archived && archiveMode(obj.Key) == "mark",
+				Icon:        getFileIcon(iconType(obj.Key, objectContentType(obj.Key, obj.ContentType))),
 			})
 		}
-
 	}
 
+	if mergeOverlay(listing, prefix, recursive) {
+		hasContent = true
+	}
 	if !hasContent {
+		return nil, nil
+	}
+	sortEntries(listing.Entries)
+	fillDownloads(listing.Entries)
+	fillDirSizes(listing.Entries)
+	return listing, nil
+}
+
+// 按标签过滤并重新统计
+func (l *Listing) filterTag(ctx context.Context, tag string) {
+	l.Entries = filterByTag(ctx, l.Entries, tag)
+	l.Files, l.Dirs, l.TotalSize = 0, 0, 0
+	for _, entry := range l.Entries {
+		if entry.IsDir {
+			l.Dirs++
+		} else {
+			l.Files++
+			l.TotalSize += entry.Bytes
+		}
+	}
+}
+
+func handleDirectory(w http.ResponseWriter, r *http.Request, prefix string) bool {
+	// 自动添加目录斜杠
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if prefix == "/" {
+		prefix = ""
+	}
+	if !listingAllowed(prefix) {
+		return denyListing(w, prefix)
+	}
+
+	recursive := r.URL.Query().Get("recursive") == "1"
+	visible := func(key string) bool { return canRead(r, key) }
+	listing, err := listDirectory(context.Background(), prefix, recursive, visible)
+	if err != nil {
+		log.Printf("目录列表错误: %v", err)
+		return false
+	}
+	if listing == nil && isHome(r, prefix) {
+		// 用户目录尚未创建时显示为空目录
+		listing = &Listing{Path: keyURL(prefix), Entries: []DirEntry{}}
+	}
+	if listing == nil {
 		return false
 	}
 
+	// 按需获取对象标签，或按标签过滤
+	showTags := r.URL.Query().Get("tags") == "1"
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		listing.filterTag(context.Background(), tag)
+	} else if showTags {
+		fillTags(context.Background(), listing.Entries)
+	}
+
+	// 输出 JSON 列表
+	switch r.URL.Query().Get("format") {
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(listing); err != nil {
+			log.Printf("响应写入失败: %v", err)
+		}
+		return true
+	case "nginx-json":
+		writeNginxJSON(w, listing.Entries)
+		return true
+	case "apache":
+		writeApacheIndex(w, r, listing, prefix != "" && !recursive)
+		return true
+	case "text":
+		writeTextListing(w, r, listing.Entries)
+		return true
+	case "":
+		if wantsText(w, r) {
+			writeTextListing(w, r, listing.Entries)
+			return true
+		}
+		if *indexStyle == "apache" {
+			writeApacheIndex(w, r, listing, prefix != "" && !recursive)
+			return true
+		}
+	}
+
+	// 树形视图与相册视图
+	switch r.URL.Query().Get("view") {
+	case "tree":
+		writeTreeView(w, listing)
+		return true
+	case "gallery":
+		writeGalleryView(w, listing)
+		return true
+	}
+
+	// 添加父目录链接
+	entries := listing.Entries
+	if prefix != "" && !recursive {
+		parent := path.Dir(strings.TrimSuffix(listing.Path, "/"))
+		if parent != "/" {
+			parent += "/"
+		}
+		entries = append([]DirEntry{{
+			URL:     parent,
+			Name:    "..",
+			Size:    "-",
+			ModTime: time.Time{},
+			IsDir:   true,
+			Icon:    getFileIcon("dir"),
+		}}, entries...)
+	}
+
+	// 文件管理界面的写请求需要 CSRF 令牌
+	manage, csrf := manageEnabled(prefix), ""
+	if manage {
+		csrf = csrfToken(w, r)
+	}
+
 	// 渲染目录列表
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	err := tmpl.Execute(w, struct {
-		Path    string
-		Entries []DirEntry
-	}{
-		Path:    "/" + prefix,
-		Entries: entries,
+	err = tmpl.Execute(w, listingPage{
+		Path:         listing.Path,
+		Entries:      entries,
+		Manage:       manage,
+		CSRF:         csrf,
+		Columns:      requestColumns(*showClass || r.URL.Query().Get("class") == "1"),
+		RelativeTime: findMount(prefix).RelativeTime,
+		ShowTags:     showTags,
+		Files:        listing.Files,
+		Dirs:         listing.Dirs,
+		TotalSize:    formatSize(listing.TotalSize),
+		Views:        true,
+		Gallery:      mostlyImages(listing.Entries),
+		Zip:          *zipDownload,
 	})
 
 	if err != nil {
@@ -267,21 +799,78 @@ func handleDirectory(w http.ResponseWriter, prefix string) bool {
 	return true
 }
 
+// 301 重定向到规范地址，保留查询参数
+func redirectCanonical(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusMovedPermanently)
+}
+
+func dirExists(prefix string) bool {
+	if overlayDirExists(prefix) {
+		return true
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: prefix, MaxKeys: 1}) {
+		return obj.Err == nil
+	}
+	return false
+}
+
+func fileExists(key string) bool {
+	if _, _, ok := overlayFile(key); ok {
+		return true
+	}
+	m, objectKey := resolveKey(key)
+	objInfo, err := statObject(context.Background(), m, objectKey, minio.StatObjectOptions{})
+	return err == nil && objInfo.ContentType != "application/x-directory"
+}
+
+// 大小单位：binary 为 1024 进制（KiB），si 为 1000 进制（kB）
+type sizeUnitFlag string
+
+var sizeUnits = sizeUnitFlag("binary")
+
+func init() {
+	flag.Var(&sizeUnits, "size-units", "The units of sizes in listings, binary (KiB, MiB) or si (kB, MB)")
+}
+
+func (u *sizeUnitFlag) String() string {
+	return string(*u)
+}
+
+func (u *sizeUnitFlag) Set(value string) error {
+	if value != "binary" && value != "si" {
+		return fmt.Errorf("invalid value %q, expect binary or si", value)
+	}
+	*u = sizeUnitFlag(value)
+	return nil
+}
+
 func formatSize(size int64) string {
-	const unit = 1024
+	unit, prefixes, suffix := int64(1024), "KMGTPE", "iB"
+	if sizeUnits == "si" {
+		unit, prefixes, suffix = 1000, "kMGTPE", "B"
+	}
 	if size < unit {
 		return fmt.Sprintf("%d B", size)
 	}
-	div, exp := int64(unit), 0
+	div, exp := unit, 0
 	for n := size / unit; n >= unit; n /= unit {
 		div *= unit
 		exp++
 	}
-	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+	return fmt.Sprintf("%.1f %c%s", float64(size)/float64(div), prefixes[exp], suffix)
 }
 
 func getContentType(key string) string {
 	ext := path.Ext(key)
+	// 配置的映射优先于内置类型
+	if contentType, ok := lookupMimeType(ext); ok && ext != "" {
+		return contentType
+	}
 	switch strings.ToLower(ext) {
 	case ".html", ".htm":
 		return "text/html"
@@ -302,15 +891,11 @@ func getContentType(key string) string {
 	}
 }
 
-// 获取文件类型图标（Base64编码）
-func getFileIcon(filename string) template.HTML {
-	ext := strings.ToLower(filename)
-
-	// 常见文件类型图标
-	switch ext {
-	case "dir":
-		return `<img src="data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAABcAAAAWCAYAAAArdgcFAAAAAXNSR0IArs4c6QAAAARnQU1BAACxjwv8YQUAAAAJcEhZcwAADsMAAA7DAcdvqGQAAAH/SURBVEhLxdPfS1phHAZw7/XCP0JBlsIkNhgULAYjUjkzByuDEAx2ZlQWZm4VhZbWoagoibGti7WwGGUUjo3KflLjELHdrLGiHxeDXQi7iA0p4qn37WRl54BIhx54vHrfj3zf9z0KyJgUHggE4HA4JMuyLJLJpLA6s1A8FAohVFsGrsYm2Y7qp2jwepBIJOjGTKLgOA4+J4O96SCw/lqyydUwmpxF6Gj3g+xJbzQaFciLKE6DmR4n/i/3iqLnPeYH8Svigbu0AA7zg2utZ+2IxWICexaKD9U9xs+ROuxPNEp2d/wVPrcX499Sj+ifz4WrYH54D/F4XKAFfHuyDQvddkw0Fkp2qsWMo69hUZhM/e2dCy+td6DVasHz/FVcbFOmJcf1viYPfnsu9Ho9QW8OJ/exPeaVB9/8UIu3L+7Lgx+uDeDHsFsefOejDxFPgTz4wUI3+MEKefDfUy2YbrXIgye+BDHfVXqLOBnv72wnXfwn5qdnuR9toq9ha7SevmdyeeSLvLx24w0rfiwmkwkjwefYjPgw2VyElb5yzHHP8CnwhF7SmPdR6h0TeNidj+9DldfWLvZXoJJJw8mP1WqFy5SD1pK7aCvLzaquU9hoNEKj0UCpVF7gJDabDTqdDgaDIesSWK1Ww2KxUDOFk5AJyEjZVqVSgWEYQUvDbzbACZHvxmyDCBW5AAAAAElFTkSuQmCC" class="icon" alt="[DIR]">`
-	default: // file
-		return `<img src="data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAABYAAAAbCAYAAAB4Kn/lAAAAAXNSR0IArs4c6QAAAARnQU1BAACxjwv8YQUAAAAJcEhZcwAADsMAAA7DAcdvqGQAAAIVSURBVEhLtZW/y3FhGMctJilFKZKB8gdYMCmbxUBRJpOYlSxYjQx+LMrERIQQFkQiGaRYlB+hZLKQrqfrzvHynuc8jsf7fusznHPd96f7nHNf9+HAfwoRq9XqX9FsNonku9zFrVYLZrMZK4xGIxSLRQgGg1Cr1Yjo79zFy+WS3GATk8kE4/EYJpMJhMNhqFQqt8qffCTGDAYDSCQSUK1WyTWVj8WYXq8H8Xj86Z3TxO12GzKZDA18p1T0ej3EYjHIZrNQKBQgn8+Dz+cDm80G9XqdjKGJvV4vaDQaGmazmdQxCoUC5HI58Pl8EIvFIBKJQCAQgFQqBZ1OR8bQxIfDAdbrNY3dbkfqGK1WCxKJBIRCIRFS8Hg8ZrHFYgEul0tDpVKROuZyucD5fIZSqQTRaPSO0+lkFm82G5jP5zQWiwWpP+Z0OkGj0SC7AnG5XMziQCAABoOBFdhUx+MRVqsVdDqdn8W4gmQyyYrHp8Bm+VGcTqfJqtkwnU7JHMxLcSQSAYfDwYrhcEjmYF6KsaNwk7Nhv9+TOZiXYrfbDUqlkhWP58NL8WOu1yvZr0xgncpb4lQqRTqMiXK5fBv5pni73UK322UE25/KW2L8O1itVkb6/f5t5Jvi0WgEoVCIEfxNUXlL/E4YxblcjqwA2/Q34IfEM/tJjCc/4vF4wO/3/wq73Q4ymexZTAVvcjicj/hW/O8C8AXGNRjtT4rvuAAAAABJRU5ErkJggg==" class="icon" alt="[FILE]">`
+// 优先使用对象自身的类型，未设置或为通用二进制类型时按扩展名推断
+func objectContentType(key, contentType string) string {
+	switch contentType {
+	case "", "application/octet-stream", "binary/octet-stream":
+		return getContentType(key)
 	}
+	return contentType
 }
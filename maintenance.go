@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// 维护页面模板
+const maintenanceTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Under maintenance</title>
+    {{template "style"}}
+</head>
+<body>
+    <h1>Under maintenance</h1>
+    <p>{{if .Message}}{{.Message}}{{else}}The service is temporarily unavailable for maintenance, please try again later.{{end}}</p>
+</body>
+</html>`
+
+var (
+	maintenanceFlag = flag.Bool("maintenance", false, "Start in maintenance mode, data requests get a 503 maintenance page until it is turned off on the admin service")
+	maintenancePage = flag.String("maintenance-page", "", "The HTML template file of the maintenance page, {{.Message}} is the message set on the admin service")
+	maintenanceTmpl = newPageTemplate("maintenance", maintenanceTemplate)
+
+	maintenance struct {
+		sync.RWMutex
+		Enabled bool   `json:"enabled"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+func startMaintenance() {
+	maintenance.Enabled = *maintenanceFlag
+	if *maintenancePage == "" {
+		return
+	}
+	text, err := os.ReadFile(*maintenancePage)
+	if err != nil {
+		log.Fatal("维护页面读取失败: ", err)
+	}
+	tmpl, err := template.New("maintenance").Parse(string(text))
+	if err != nil {
+		log.Fatal("维护页面解析失败: ", err)
+	}
+	template.Must(tmpl.New("style").Parse(pageStyle))
+	maintenanceTmpl = tmpl
+}
+
+// 维护期间所有数据请求返回 503，/healthz 与管理服务不受影响
+func withMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maintenance.RLock()
+		enabled, message := maintenance.Enabled, maintenance.Message
+		maintenance.RUnlock()
+		if !enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Retry-After", "300")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		if err := maintenanceTmpl.Execute(w, struct{ Message string }{message}); err != nil {
+			log.Printf("模板渲染失败: %v", err)
+		}
+	})
+}
+
+// 管理服务上的维护开关：PUT 开启（可带 message 参数），DELETE 关闭，GET 查看状态
+func handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	maintenance.Lock()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		maintenance.Enabled, maintenance.Message = true, r.URL.Query().Get("message")
+		log.Println("已进入维护模式")
+	case http.MethodDelete:
+		maintenance.Enabled, maintenance.Message = false, ""
+		log.Println("已退出维护模式")
+	default:
+		maintenance.Unlock()
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, _ := json.Marshal(&maintenance)
+	maintenance.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var managePrefixes stringList
+
+func init() {
+	flag.Var(&managePrefixes, "manage-prefix", "The prefix where the file manager UI is enabled, can be repeated")
+}
+
+// 是否为该目录启用文件管理界面
+func manageEnabled(prefix string) bool {
+	return *writable && !*readOnly && managePrefixes.matchPrefix(prefix)
+}
+
+// 存在受保留期或法律保留保护的对象时拒绝删除，并列出原因
+func checkUnlocked(ctx context.Context, w http.ResponseWriter, keys []string) bool {
+	locked, err := lockedObjects(ctx, keys)
+	if err != nil {
+		log.Printf("文件检查失败: %v", err)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return false
+	}
+	if len(locked) > 0 {
+		http.Error(w, "423 Locked\n"+strings.Join(locked, "\n"), http.StatusLocked)
+		return false
+	}
+	return true
+}
+
+// 删除文件，以斜杠结尾时删除整个目录。
+// 未指定版本号，开启版本控制的桶上只会写入删除标记，可从回收站恢复
+func handleDelete(w http.ResponseWriter, r *http.Request, key string) {
+	ctx := context.Background()
+	if key == "" {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !strings.HasSuffix(key, "/") {
+		if !checkUnlocked(ctx, w, []string{key}) {
+			return
+		}
+		m, objectKey := resolveKey(key)
+		if err := m.client().RemoveObject(ctx, m.Bucket, objectKey, minio.RemoveObjectOptions{}); err != nil {
+			log.Printf("文件删除失败: %v", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var keys []string
+	found := false
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: key, Recursive: true}) {
+		if obj.Err != nil {
+			log.Printf("目录列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		found = true
+		// 访问规则对象与无权写入的对象保留
+		if !canWrite(r, obj.Key) {
+			continue
+		}
+		keys = append(keys, obj.Key)
+	}
+	if !found {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	if len(keys) == 0 {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkUnlocked(ctx, w, keys) {
+		return
+	}
+	if failures := removeObjects(ctx, keys); failures > 0 {
+		http.Error(w, fmt.Sprintf("500 Internal Server Error\n%d of %d objects could not be removed", failures, len(keys)), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeleteFile(t *testing.T) {
+	enableWrites(t, credentialList{"u": "p"})
+	base := startTestGateway(t, map[string]string{"d/a.txt": "a"})
+
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/d/a.txt", "", nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("anonymous DELETE: %s", resp.Status)
+	}
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/d/a.txt", "u", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE: %s", resp.Status)
+	}
+	if resp, _ := doRequest(t, http.MethodGet, base+"/d/a.txt", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("file after DELETE: %s", resp.Status)
+	}
+}
+
+// 删除目录时保留访问规则对象与无权写入的子目录
+func TestDeletePrefix(t *testing.T) {
+	enableWrites(t, credentialList{"u": "p", "v": "p"})
+	base := startTestGateway(t, map[string]string{
+		"d/a.txt":     "a",
+		"d/b/b.txt":   "b",
+		"d/p/c.txt":   "c",
+		"d/p/.access": "write: v",
+	})
+
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/d/", "u", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE: %s", resp.Status)
+	}
+	for _, name := range []string{"d/a.txt", "d/b/b.txt"} {
+		if resp, _ := doRequest(t, http.MethodGet, base+"/"+name, "", nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s after DELETE: %s", name, resp.Status)
+		}
+	}
+	if _, body := doRequest(t, http.MethodGet, base+"/d/p/c.txt", "", nil); body != "c" {
+		t.Errorf("unwritable d/p/c.txt after DELETE: got %q", body)
+	}
+
+	// 只剩无权写入的对象时拒绝
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/d/p/", "u", nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("DELETE of an unwritable prefix: %s", resp.Status)
+	}
+	if resp, _ := doRequest(t, http.MethodDelete, base+"/missing/", "u", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("DELETE of a missing prefix: %s", resp.Status)
+	}
+}
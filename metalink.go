@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"flag"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var mirrorURLs stringList
+
+func init() {
+	flag.Var(&mirrorURLs, "mirror-url", "The base URL of another mirror listed in metalinks, can be repeated")
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type metalinkURL struct {
+	Priority int    `xml:"priority,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type metalinkFile struct {
+	Name string        `xml:"name,attr"`
+	Size int64         `xml:"size"`
+	Hash metalinkHash  `xml:"hash"`
+	URLs []metalinkURL `xml:"url"`
+}
+
+type metalink struct {
+	XMLName   xml.Name     `xml:"urn:ietf:params:xml:ns:metalink metalink"`
+	Published string       `xml:"published"`
+	File      metalinkFile `xml:"file"`
+}
+
+// 为 file.meta4 生成 file 的 Metalink 描述（RFC 5854）
+func handleMetalink(w http.ResponseWriter, r *http.Request, key string) bool {
+	ctx := context.Background()
+	key = strings.TrimSuffix(key, ".meta4")
+	m, objectKey := resolveKey(key)
+	objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{})
+	if err != nil || objInfo.ContentType == "application/x-directory" {
+		return false
+	}
+	if !fileAllowed(key, objectContentType(key, objInfo.ContentType)) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return true
+	}
+	sum, err := objectSHA256(ctx, key, objInfo.ETag)
+	if err != nil {
+		log.Printf("校验和计算失败: %v", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return true
+	}
+
+	doc := metalink{
+		Published: objInfo.LastModified.UTC().Format(time.RFC3339),
+		File: metalinkFile{
+			Name: path.Base(key),
+			Size: objInfo.Size,
+			Hash: metalinkHash{Type: "sha-256", Value: sum},
+			URLs: []metalinkURL{{Priority: 1, Value: baseURL(r) + escapePath(keyURL(key))}},
+		},
+	}
+	// 与页面链接相同，按路径分段转义
+	for i, mirror := range mirrorURLs {
+		doc.File.URLs = append(doc.File.URLs, metalinkURL{
+			Priority: i + 2,
+			Value:    strings.TrimSuffix(mirror, "/") + "/" + escapePath(key),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/metalink4+xml")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Printf("响应写入失败: %v", err)
+	}
+	return true
+}
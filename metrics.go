@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 后端耗时直方图的上界（秒）
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type histogram struct {
+	counts []uint64 // 与 latencyBuckets 对应，最后一项为 +Inf
+	sum    float64
+	count  uint64
+	errors uint64
+}
+
+type metricKey struct {
+	operation string
+	mount     string
+}
+
+var backendMetrics struct {
+	sync.Mutex
+	series map[metricKey]*histogram
+}
+
+// 记录一次后端调用，对象不存在或请求被取消不算错误
+func observeBackend(operation string, m *Mount, start time.Time, err error) {
+	elapsed := time.Since(start).Seconds()
+	mount := "/" + m.Path
+	backendMetrics.Lock()
+	defer backendMetrics.Unlock()
+	if backendMetrics.series == nil {
+		backendMetrics.series = map[metricKey]*histogram{}
+	}
+	key := metricKey{operation, mount}
+	h := backendMetrics.series[key]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets)+1)}
+		backendMetrics.series[key] = h
+	}
+	i := sort.SearchFloat64s(latencyBuckets, elapsed)
+	h.counts[i]++
+	h.sum += elapsed
+	h.count++
+	if err != nil && minio.ToErrorResponse(err).Code != "NoSuchKey" && !errors.Is(err, context.Canceled) {
+		h.errors++
+	}
+}
+
+// 以 Prometheus 文本格式输出指标
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	backendMetrics.Lock()
+	keys := make([]metricKey, 0, len(backendMetrics.series))
+	for key := range backendMetrics.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].mount < keys[j].mount
+	})
+
+	var b strings.Builder
+	b.WriteString("# HELP bucket2http_backend_request_duration_seconds Latency of requests to oss.\n")
+	b.WriteString("# TYPE bucket2http_backend_request_duration_seconds histogram\n")
+	for _, key := range keys {
+		h := backendMetrics.series[key]
+		labels := fmt.Sprintf("operation=%q,mount=%q", key.operation, key.mount)
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "bucket2http_backend_request_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, le, cumulative)
+		}
+		fmt.Fprintf(&b, "bucket2http_backend_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "bucket2http_backend_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(&b, "bucket2http_backend_request_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+	b.WriteString("# HELP bucket2http_backend_errors_total Failed requests to oss.\n")
+	b.WriteString("# TYPE bucket2http_backend_errors_total counter\n")
+	for _, key := range keys {
+		fmt.Fprintf(&b, "bucket2http_backend_errors_total{operation=%q,mount=%q} %d\n", key.operation, key.mount, backendMetrics.series[key].errors)
+	}
+	backendMetrics.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
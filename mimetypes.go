@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var mimeTypesPath = flag.String("mime-types", "", "A nginx or Apache style mime.types file mapping extensions to content types, reloaded on change")
+
+// 扩展名（小写，不含点）到类型的映射，文件修改后自动重新加载
+var mimeTypes struct {
+	sync.RWMutex
+	types   map[string]string
+	modTime time.Time
+}
+
+// 同时支持 nginx 的 types { type ext ...; } 与 Apache 每行一条的格式
+func parseMimeTypes(data string) map[string]string {
+	var lines []string
+	for _, line := range strings.Split(data, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		lines = append(lines, line)
+	}
+	text := strings.Join(lines, "\n")
+	text = strings.TrimSpace(text)
+	if rest, ok := strings.CutPrefix(text, "types"); ok {
+		text = strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), "{")), "}")
+	}
+	sep := "\n"
+	if strings.Contains(text, ";") {
+		sep = ";"
+	}
+
+	types := map[string]string{}
+	for _, statement := range strings.Split(text, sep) {
+		fields := strings.Fields(statement)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, ext := range fields[1:] {
+			types[strings.ToLower(strings.TrimPrefix(ext, "."))] = fields[0]
+		}
+	}
+	return types
+}
+
+func loadMimeTypes(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	mimeTypes.RLock()
+	unchanged := info.ModTime().Equal(mimeTypes.modTime)
+	mimeTypes.RUnlock()
+	if unchanged {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	types := parseMimeTypes(string(data))
+	mimeTypes.Lock()
+	mimeTypes.types, mimeTypes.modTime = types, info.ModTime()
+	mimeTypes.Unlock()
+	log.Printf("MIME 类型已加载: %d 个扩展名", len(types))
+	return nil
+}
+
+func startMimeTypes() {
+	if *mimeTypesPath == "" {
+		return
+	}
+	if err := loadMimeTypes(*mimeTypesPath); err != nil {
+		log.Fatal("MIME 类型文件加载失败: ", err)
+	}
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			if err := loadMimeTypes(*mimeTypesPath); err != nil {
+				log.Printf("MIME 类型文件加载失败: %v", err)
+			}
+		}
+	}()
+}
+
+// 按配置的映射查找扩展名对应的类型
+func lookupMimeType(ext string) (string, bool) {
+	mimeTypes.RLock()
+	defer mimeTypes.RUnlock()
+	contentType, ok := mimeTypes.types[strings.ToLower(strings.TrimPrefix(ext, "."))]
+	return contentType, ok
+}
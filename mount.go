@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 多存储桶时的根目录页面模板
+const mountIndexTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Index of /</title>
+    {{template "style"}}
+</head>
+<body>
+    <h1>Index of /</h1>
+    <table>
+        <tr><th>Name</th><th>Description</th></tr>
+        {{range .}}
+        <tr>
+            <td><a href="/{{.Path}}">{{.Path}}</a></td>
+            <td>{{.Description}}</td>
+        </tr>
+        {{end}}
+    </table>
+</body>
+</html>`
+
+// 挂载到公开路径下的存储桶
+type Mount struct {
+	Path         string `json:"path"` // 以斜杠结尾，只有一个存储桶时为空
+	Bucket       string `json:"bucket"`
+	Prefix       string `json:"-"` // 存储桶内对应的 key 前缀，只用于路由
+	Description  string `json:"description,omitempty"`
+	RelativeTime bool   `json:"-"`
+	backend      *backendClient
+}
+
+func (m *Mount) client() *minio.Client {
+	return m.backend.client()
+}
+
+var (
+	mountBuckets      prefixMap
+	routeBuckets      prefixMap
+	mountDescriptions = valueMap{}
+	mountEndpoints    = valueMap{}
+	mountAccessKeys   = valueMap{}
+	mountSecretKeys   = valueMap{}
+	mountRegions      = valueMap{}
+	allBuckets        = flag.Bool("all-buckets", false, "Mount every bucket visible to the credentials at /<bucket>/")
+	mountIndexTmpl    = newPageTemplate("mounts", mountIndexTemplate)
+	mounts            []*Mount
+	routes            []*Mount // 挂载点内路由到其他存储桶或端点的前缀
+)
+
+func init() {
+	flag.Var(&mountBuckets, "mount", "The bucket mounted at a public path as path=bucket, can be repeated")
+	flag.Var(&routeBuckets, "route", "The prefix inside a mount served from another bucket as path=bucket or path=bucket/prefix, the -mount-* flags of path select its endpoint, can be repeated")
+	flag.Var(mountDescriptions, "mount-description", "The description of a mount on the root index as path=text, can be repeated")
+	flag.Var(mountEndpoints, "mount-endpoint", "The endpoint of oss for a mount or route as path=host:port, https:// for TLS, can be repeated")
+	flag.Var(mountAccessKeys, "mount-access-key", "The access key of oss for a mount or route as path=key, can be repeated")
+	flag.Var(mountSecretKeys, "mount-secret-key", "The secret key of oss for a mount or route as path=key, can be repeated")
+	flag.Var(mountRegions, "mount-region", "The region of the bucket for a mount or route as path=region, {region} in its endpoint is replaced, can be repeated")
+}
+
+// 挂载点单独配置的后端，相同配置共用一个客户端。
+// names 依次为自身与所在挂载点的路径，未配置的项沿用所在挂载点，再沿用全局参数
+func mountBackend(backends map[string]*backendClient, names ...string) (*backendClient, error) {
+	setting := func(values valueMap) string {
+		for _, name := range names {
+			if v := values[name]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	ep, ak, sk, rg := setting(mountEndpoints), setting(mountAccessKeys), setting(mountSecretKeys), setting(mountRegions)
+	if ep == "" && ak == "" && sk == "" && rg == "" {
+		return defaultBackend, nil
+	}
+	if ep == "" {
+		// 本地目录后端不区分凭据与区域
+		if *backendKind == "fs" {
+			return defaultBackend, nil
+		}
+		ep = *endpoint
+	}
+	if ak == "" {
+		ak = *accessKey
+	}
+	if sk == "" {
+		sk = *secretKey
+	}
+	if rg == "" {
+		rg = *region
+	}
+	id := ep + "\x00" + ak + "\x00" + sk + "\x00" + rg
+	if b, ok := backends[id]; ok {
+		return b, nil
+	}
+	b, err := newBackend(ep, ak, sk, rg)
+	if err != nil {
+		return nil, err
+	}
+	backends[id] = b
+	return b, nil
+}
+
+// 根据参数建立挂载表，未配置时只挂载 -bucket 到根路径
+func setupMounts(ctx context.Context) error {
+	mounts = nil
+	for _, item := range mountBuckets {
+		mounts = append(mounts, &Mount{Path: item.Prefix, Bucket: item.Value})
+	}
+	if *allBuckets {
+		buckets, err := defaultBackend.client().ListBuckets(ctx)
+		if err != nil {
+			return err
+		}
+		for _, b := range buckets {
+			mounts = append(mounts, &Mount{Path: b.Name, Bucket: b.Name})
+		}
+	}
+	if len(mounts) == 0 {
+		mounts = append(mounts, &Mount{Bucket: *bucket})
+	}
+
+	backends := map[string]*backendClient{}
+	for _, m := range mounts {
+		if m.Path != "" && !strings.HasSuffix(m.Path, "/") {
+			m.Path += "/"
+		}
+		name := strings.Trim(m.Path, "/")
+		m.Description = mountDescriptions[name]
+		m.RelativeTime = mountRelativeTime(name)
+		b, err := mountBackend(backends, name)
+		if err != nil {
+			return err
+		}
+		m.backend = b
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Path < mounts[j].Path })
+	return setupRoutes(backends)
+}
+
+// 建立路由表，未指定前缀时在目标存储桶中使用与原挂载点内相同的 key
+func setupRoutes(backends map[string]*backendClient) error {
+	routes = nil
+	for _, item := range routeBuckets {
+		routePath := item.Prefix
+		if !strings.HasSuffix(routePath, "/") {
+			routePath += "/"
+		}
+		parent := findMount(routePath)
+		if parent == nil || parent.Path == routePath {
+			return fmt.Errorf("route %s is not inside a mount", item.Prefix)
+		}
+		bucketName, prefix, ok := strings.Cut(item.Value, "/")
+		if !ok {
+			prefix = strings.TrimPrefix(routePath, parent.Path)
+		} else if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+		// 未单独配置时使用所在挂载点的后端
+		b, err := mountBackend(backends, strings.Trim(routePath, "/"), strings.Trim(parent.Path, "/"))
+		if err != nil {
+			return err
+		}
+		routes = append(routes, &Mount{
+			Path:         routePath,
+			Bucket:       bucketName,
+			Prefix:       prefix,
+			RelativeTime: parent.RelativeTime,
+			backend:      b,
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	return nil
+}
+
+func multiMount() bool {
+	return len(mounts) > 1 || mounts[0].Path != ""
+}
+
+// 查找 key 所属的挂载点，不属于任何挂载点时返回 nil
+func findMount(key string) *Mount {
+	var found *Mount
+	for _, m := range mounts {
+		if strings.HasPrefix(key, m.Path) && (found == nil || len(m.Path) > len(found.Path)) {
+			found = m
+		}
+	}
+	return found
+}
+
+// 查找 key 实际所在的存储位置，路由优先于所在的挂载点
+func findTarget(key string) *Mount {
+	var found *Mount
+	for _, r := range routes {
+		if strings.HasPrefix(key, r.Path) && (found == nil || len(r.Path) > len(found.Path)) {
+			found = r
+		}
+	}
+	if found != nil {
+		return found
+	}
+	return findMount(key)
+}
+
+// 将 key 拆分为挂载点（或路由）与存储桶内的对象 key
+func resolveKey(key string) (*Mount, string) {
+	m := findTarget(key)
+	if m == nil {
+		// 调用方应已检查过挂载点，这里返回一个必然失败的空挂载
+		return &Mount{backend: defaultBackend}, key
+	}
+	return m, m.Prefix + strings.TrimPrefix(key, m.Path)
+}
+
+// 按 opts.Prefix 所在挂载点列出对象，返回的 Key 带有挂载路径；其下的路由递归时一并列出，否则作为子目录列出
+func listObjects(ctx context.Context, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	out := make(chan minio.ObjectInfo)
+	m := findTarget(opts.Prefix)
+	if m == nil {
+		close(out)
+		return out
+	}
+	go func() {
+		defer close(out)
+		dirs := map[string]bool{}
+		if !listTarget(ctx, m, opts, out, dirs) {
+			return
+		}
+		for _, r := range routes {
+			if r == m || r.Path == opts.Prefix || !strings.HasPrefix(r.Path, opts.Prefix) {
+				continue
+			}
+			if opts.Recursive {
+				sub := opts
+				sub.Prefix = r.Path
+				if !listTarget(ctx, r, sub, out, nil) {
+					return
+				}
+				continue
+			}
+			rest := strings.TrimPrefix(r.Path, opts.Prefix)
+			dir := opts.Prefix + rest[:strings.Index(rest, "/")+1]
+			if dirs[dir] {
+				continue
+			}
+			dirs[dir] = true
+			select {
+			case out <- minio.ObjectInfo{Key: dir}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// 列出单个存储位置，跳过被路由覆盖的 key；ctx 取消时返回 false
+func listTarget(ctx context.Context, m *Mount, opts minio.ListObjectsOptions, out chan<- minio.ObjectInfo, dirs map[string]bool) bool {
+	opts.Prefix = m.Prefix + strings.TrimPrefix(opts.Prefix, m.Path)
+	// list-timeout 限制等待下一个条目的时间，调用方处理条目的时间不计入
+	listCtx, wd := newWatchdog(ctx, *listTimeout)
+	defer wd.release()
+	start := time.Now()
+	ch := m.client().ListObjects(listCtx, m.Bucket, opts)
+
+	// 以收到第一个条目（或结束）的耗时作为列表延迟
+	observed := false
+	for obj := range ch {
+		wd.disarm()
+		if !observed {
+			observeBackend("ListObjects", m, start, obj.Err)
+			observed = true
+		}
+		if obj.Err == nil {
+			obj.Key = m.Path + strings.TrimPrefix(obj.Key, m.Prefix)
+			if t := findTarget(obj.Key); t != m && obj.Key != t.Path {
+				wd.reset()
+				continue
+			}
+			if dirs != nil && strings.HasSuffix(obj.Key, "/") {
+				dirs[obj.Key] = true
+			}
+		}
+		select {
+		case out <- obj:
+		case <-ctx.Done():
+			return false
+		}
+		wd.reset()
+	}
+	if !observed {
+		observeBackend("ListObjects", m, start, nil)
+	}
+	if listCtx.Err() != nil && ctx.Err() == nil {
+		log.Printf("列出 %s 超时", m.Path+strings.TrimPrefix(opts.Prefix, m.Prefix))
+	}
+	return true
+}
+
+// 多存储桶时的根目录，列出所有挂载点
+func handleMountIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(mounts); err != nil {
+			log.Printf("响应写入失败: %v", err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := mountIndexTmpl.Execute(w, mounts); err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+)
+
+var origins prefixMap
+
+func init() {
+	flag.Var(&origins, "origin", "The prefix=url proxied to when an object is missing in the bucket, can be repeated")
+}
+
+// 桶中不存在时将请求转发到该前缀配置的源站，不做存储
+func handleOrigin(w http.ResponseWriter, r *http.Request, key string) bool {
+	origin, ok := origins.lookup(key)
+	if !ok {
+		return false
+	}
+	// 按路径分段转义，key 中的 ?、# 不会被当作查询参数或片段
+	target, err := url.Parse(strings.TrimSuffix(origin.Value, "/") + "/" + escapePath(strings.TrimPrefix(key, origin.Prefix)))
+	if err != nil {
+		log.Printf("源站地址错误: %v", err)
+		return false
+	}
+	target.RawQuery = r.URL.RawQuery
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = target
+			pr.Out.Host = target.Host
+		},
+	}
+	proxy.ServeHTTP(w, r)
+	return true
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	overlayDir   = flag.String("overlay", "", "The local directory layered over the buckets, its files are served instead of objects with the same key")
+	overlayBelow = flag.Bool("overlay-below", false, "Use the -overlay directory only for keys missing from the bucket")
+)
+
+// 查找覆盖目录中与 key 对应的普通文件
+func overlayFile(key string) (string, os.FileInfo, bool) {
+	if *overlayDir == "" || key == "" {
+		return "", nil, false
+	}
+	file, ok := fsPath(*overlayDir, key)
+	if !ok {
+		return "", nil, false
+	}
+	info, err := os.Stat(file)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", nil, false
+	}
+	return file, info, true
+}
+
+// 覆盖目录中是否存在 prefix 对应的子目录
+func overlayDirExists(prefix string) bool {
+	if *overlayDir == "" {
+		return false
+	}
+	dir, ok := fsPath(*overlayDir, prefix)
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
+// 从覆盖目录返回文件，不存在时返回 false
+func serveOverlay(w http.ResponseWriter, r *http.Request, key string) bool {
+	if r.URL.Query().Get("versionId") != "" {
+		return false
+	}
+	file, info, ok := overlayFile(key)
+	if !ok {
+		return false
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		log.Printf("覆盖文件打开失败: %v", err)
+		return false
+	}
+	defer f.Close()
+	contentType := objectContentType(key, "")
+	if !fileAllowed(key, contentType) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return true
+	}
+
+	release, ok := acquireDownload(r)
+	if !ok {
+		rejectDownload(w, r)
+		return true
+	}
+	defer release()
+
+	// 由 ServeContent 处理 Range 与 If-None-Match、If-Modified-Since 等条件请求
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", fsETag(info))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+	return true
+}
+
+// 将覆盖目录中的文件与子目录合并到列表中，返回该目录是否存在
+func mergeOverlay(listing *Listing, prefix string, recursive bool) bool {
+	if *overlayDir == "" {
+		return false
+	}
+	base := *overlayDir
+	if prefix != "" {
+		var ok bool
+		if base, ok = fsPath(*overlayDir, prefix); !ok {
+			return false
+		}
+	}
+	delimiter := "/"
+	if recursive {
+		delimiter = ""
+	}
+	objects, dirs, err := listFiles(*overlayDir, prefix, delimiter)
+	if err != nil {
+		log.Printf("覆盖目录读取失败: %v", err)
+	}
+	if info, err := os.Stat(base); err != nil || !info.IsDir() {
+		return false
+	}
+
+	index := map[string]int{}
+	for i, entry := range listing.Entries {
+		index[entry.Key] = i
+	}
+	for key := range dirs {
+		if _, ok := index[key]; ok || isAccessFile(key) {
+			continue
+		}
+		listing.Dirs++
+		listing.Entries = append(listing.Entries, DirEntry{
+			Key:   key,
+			URL:   keyURL(key),
+			Name:  strings.TrimSuffix(strings.TrimPrefix(key, prefix), "/"),
+			Size:  "-",
+			IsDir: true,
+			Icon:  getFileIcon("dir"),
+		})
+	}
+	for key := range objects {
+		if isAccessFile(key) || (recursive && !keyListable(key)) {
+			continue
+		}
+		_, info, ok := overlayFile(key)
+		if !ok {
+			continue
+		}
+		name := path.Base(key)
+		if recursive {
+			name = strings.TrimPrefix(key, prefix)
+		}
+		contentType := objectContentType(key, "")
+		entry := DirEntry{
+			Key:         key,
+			URL:         keyURL(key),
+			Name:        name,
+			Size:        formatSize(info.Size()),
+			Bytes:       info.Size(),
+			ModTime:     info.ModTime(),
+			ContentType: contentType,
+			Icon:        getFileIcon(iconType(key, contentType)),
+		}
+		i, exists := index[key]
+		switch {
+		case !exists:
+			listing.Files++
+			listing.TotalSize += entry.Bytes
+			listing.Entries = append(listing.Entries, entry)
+		case !*overlayBelow && !listing.Entries[i].IsDir:
+			listing.TotalSize += entry.Bytes - listing.Entries[i].Bytes
+			listing.Entries[i] = entry
+		}
+	}
+	return true
+}
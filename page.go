@@ -0,0 +1,51 @@
+package main
+
+import "html/template"
+
+// 附属页面（回收站、版本历史等）共用的样式
+const pageStyle = `
+    <style>
+        body {
+            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+            margin: 20px;
+            font-size: 13px;
+            color: #333;
+        }
+        h1 {
+            font-size: 15px;
+            margin: 0 0 12px 0;
+            padding-bottom: 5px;
+            border-bottom: 1px solid #eee;
+        }
+        table {
+            border-collapse: collapse;
+            width: 100%;
+            line-height: 1.4;
+        }
+        th {
+            text-align: left;
+            padding: 4px 8px;
+            background-color: #f8f9fa;
+            border-bottom: 2px solid #ddd;
+            font-weight: 500;
+        }
+        td {
+            padding: 3px 8px;
+            border-bottom: 1px solid #eee;
+        }
+        a {
+            text-decoration: none;
+            color: #0366d6;
+        }
+        a:hover {
+            text-decoration: underline;
+        }
+    </style>`
+
+func newPageTemplate(name, text string) *template.Template {
+	tmpl := template.Must(template.New(name).Funcs(template.FuncMap{
+		"formatSize": formatSize,
+	}).Parse(text))
+	template.Must(tmpl.New("style").Parse(pageStyle))
+	return tmpl
+}
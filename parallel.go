@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	parallelThreshold = flag.Int64("parallel-fetch-threshold", 0, "The size in bytes above which objects are fetched from oss as several concurrent ranges, 0 disables it")
+	parallelPartSize  = flag.Int64("parallel-fetch-part-size", 8<<20, "The size in bytes of each range of a parallel fetch")
+	parallelParts     = flag.Int("parallel-fetch-parts", 4, "The number of ranges of a parallel fetch requested at the same time")
+)
+
+type partResult struct {
+	data []byte
+	err  error
+}
+
+// 并发请求多个范围并按顺序拼接，单个后端连接跑不满客户端带宽时使用
+type parallelReader struct {
+	first   io.ReadCloser // 已打开的完整请求，只读取第一段，读完即关闭
+	current io.Reader
+	parts   chan chan partResult
+	cancel  context.CancelFunc
+}
+
+// 大于阈值时改为并发分段获取，first 为已打开的对象
+func fetchParallel(ctx context.Context, m *Mount, objectKey, versionID string, objInfo minio.ObjectInfo, first io.ReadCloser) io.ReadCloser {
+	if *parallelThreshold <= 0 || objInfo.Size <= *parallelThreshold || objInfo.Size <= *parallelPartSize {
+		return first
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &parallelReader{
+		first:   first,
+		current: io.LimitReader(first, *parallelPartSize),
+		parts:   make(chan chan partResult, max(*parallelParts-1, 0)),
+		cancel:  cancel,
+	}
+	go func() {
+		defer close(r.parts)
+		for offset := *parallelPartSize; offset < objInfo.Size; offset += *parallelPartSize {
+			end := min(offset+*parallelPartSize, objInfo.Size) - 1
+			result := make(chan partResult, 1)
+			// 队列已满时等待，同时进行中的范围不超过 parallel-fetch-parts
+			select {
+			case r.parts <- result:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				result <- fetchRange(ctx, m, objectKey, versionID, objInfo.ETag, offset, end)
+			}()
+		}
+	}()
+	return r
+}
+
+func fetchRange(ctx context.Context, m *Mount, objectKey, versionID, etag string, start, end int64) partResult {
+	opts := minio.GetObjectOptions{VersionID: versionID}
+	opts.SetRange(start, end)
+	// 确保各段来自同一版本的对象
+	opts.SetMatchETag(etag)
+	object, _, _, err := getObject(ctx, m, objectKey, opts)
+	if err != nil {
+		return partResult{err: err}
+	}
+	defer object.Close()
+	data := make([]byte, end-start+1)
+	if _, err := io.ReadFull(object, data); err != nil {
+		return partResult{err: err}
+	}
+	return partResult{data: data}
+}
+
+func (r *parallelReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.current.Read(p)
+		if n > 0 || err != io.EOF {
+			return n, err
+		}
+		if r.first != nil {
+			r.first.Close()
+			r.first = nil
+		}
+		next, ok := <-r.parts
+		if !ok {
+			return 0, io.EOF
+		}
+		part := <-next
+		if part.err != nil {
+			return 0, part.err
+		}
+		r.current = bytes.NewReader(part.data)
+	}
+}
+
+func (r *parallelReader) Close() error {
+	r.cancel()
+	if r.first != nil {
+		return r.first.Close()
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/minio/minio-go/v7"
+)
+
+// 文本预览页面模板
+const previewTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>{{.Path}}</title>
+    {{template "style"}}
+    <style>
+        pre {
+            font-size: 12px;
+        }
+    </style>
+</head>
+<body>
+    <h1>{{.Path}} <a href="{{.Path}}">raw</a></h1>
+    {{if .Truncated}}<p>Only the first {{formatSize .Limit}} of {{formatSize .Size}} is shown.</p>{{end}}
+    {{.Code}}
+</body>
+</html>`
+
+var (
+	previewMaxSize = flag.Int64("preview-max-size", 1<<20, "The maximum bytes of a text object rendered by ?view=1")
+	previewTmpl    = newPageTemplate("preview", previewTemplate)
+)
+
+// 对文本文件做语法高亮预览
+func handlePreview(w http.ResponseWriter, r *http.Request, key string) bool {
+	m, objectKey := resolveKey(key)
+	obj, info, _, err := getObject(context.Background(), m, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey":
+			return false
+		case "InvalidObjectState":
+			handleArchived(w, r, key)
+			return true
+		}
+		log.Printf("文件获取失败: %v", err)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return true
+	}
+	defer obj.Close()
+
+	if info.ContentType == "application/x-directory" {
+		return false
+	}
+	if !fileAllowed(key, objectContentType(key, info.ContentType)) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return true
+	}
+
+	// 只读取前 previewMaxSize 字节
+	data, err := io.ReadAll(io.LimitReader(obj, *previewMaxSize))
+	if err != nil {
+		log.Printf("文件读取失败: %v", err)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return true
+	}
+	truncated := info.Size > int64(len(data))
+	if truncated {
+		// 去掉截断处不完整的字符
+		for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+			data = data[:len(data)-1]
+		}
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		http.Error(w, "415 Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return true
+	}
+
+	code, err := highlight(key, string(data))
+	if err != nil {
+		log.Printf("语法高亮失败: %v", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = previewTmpl.Execute(w, struct {
+		Path      string
+		Code      template.HTML
+		Truncated bool
+		Limit     int64
+		Size      int64
+	}{keyURL(key), code, truncated, *previewMaxSize, info.Size})
+	if err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+	return true
+}
+
+// 按文件名选择语法，无法识别时按内容猜测
+func highlight(key, text string) (template.HTML, error) {
+	lexer := lexers.Match(path.Base(key))
+	if lexer == nil {
+		lexer = lexers.Analyse(text)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	formatter := html.New(html.WithLineNumbers(true), html.WithLinkableLineNumbers(true, "L"))
+	if err := formatter.Format(&buf, styles.Get("github"), iterator); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var backendProxyURL = flag.String("backend-proxy", "", "The proxy URL (http://, https:// or socks5://) of oss connections, HTTP_PROXY, HTTPS_PROXY and ALL_PROXY are used when empty")
+
+// 后端连接使用的代理，未指定时读取环境变量，ALL_PROXY 作为 HTTP_PROXY/HTTPS_PROXY 的缺省值
+var backendProxy = sync.OnceValue(func() func(*http.Request) (*url.URL, error) {
+	if *backendProxyURL != "" {
+		u, err := url.Parse(*backendProxyURL)
+		if err != nil {
+			log.Fatal("代理地址无效: ", err)
+		}
+		return http.ProxyURL(u)
+	}
+
+	config := httpproxy.FromEnvironment()
+	all := os.Getenv("ALL_PROXY")
+	if all == "" {
+		all = os.Getenv("all_proxy")
+	}
+	if config.HTTPProxy == "" {
+		config.HTTPProxy = all
+	}
+	if config.HTTPSProxy == "" {
+		config.HTTPSProxy = all
+	}
+	proxy := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+})
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	upstream = flag.String("upstream", "", "The upstream mirror URL fetched and stored into the bucket on a miss")
+
+	// 正在回源存储的 key，避免并发请求重复写入
+	pulling   = map[string]bool{}
+	pullingMu sync.Mutex
+)
+
+// 透传的上游响应头
+var upstreamHeaders = []string{"Content-Type", "Content-Length", "Last-Modified", "ETag"}
+
+// 从上游获取文件返回给客户端，同时写入临时文件，完成后异步存入桶中
+func handlePullThrough(w http.ResponseWriter, r *http.Request, key string) bool {
+	if *upstream == "" {
+		return false
+	}
+
+	// key 中的 ?、#、% 等字符需要转义，否则会被当作查询参数或片段
+	resp, err := http.Get(strings.TrimSuffix(*upstream, "/") + "/" + escapePath(key))
+	if err != nil {
+		log.Printf("上游获取失败: %v", err)
+		http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+		return true
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	for _, name := range upstreamHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+
+	// HEAD 请求或已有请求在回源时只转发
+	pullingMu.Lock()
+	store := r.Method == http.MethodGet && !pulling[key]
+	if store {
+		pulling[key] = true
+	}
+	pullingMu.Unlock()
+	if !store {
+		if _, err := copyBuffered(w, resp.Body); err != nil {
+			log.Printf("响应写入失败: %v", err)
+		}
+		return true
+	}
+
+	tmp, err := os.CreateTemp("", "bucket2http-")
+	if err != nil {
+		log.Printf("临时文件创建失败: %v", err)
+		finishPull(key, nil)
+		copyBuffered(w, resp.Body)
+		return true
+	}
+
+	// 上游读取完整后才存储，客户端中途断开不影响回源
+	_, err = copyBuffered(io.MultiWriter(tmp, writerIgnoringErrors{w}), resp.Body)
+	if err != nil || (resp.ContentLength >= 0 && !sizeMatches(tmp, resp.ContentLength)) {
+		log.Printf("上游读取失败: %v", err)
+		finishPull(key, tmp)
+		return true
+	}
+	go storePulled(key, resp.Header.Get("Content-Type"), tmp)
+	return true
+}
+
+func sizeMatches(f *os.File, size int64) bool {
+	info, err := f.Stat()
+	return err == nil && info.Size() == size
+}
+
+func storePulled(key, contentType string, tmp *os.File) {
+	defer finishPull(key, tmp)
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		log.Printf("回源存储失败: %v", err)
+		return
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		log.Printf("回源存储失败: %v", err)
+		return
+	}
+	if contentType == "" {
+		contentType = getContentType(key)
+	}
+	m, objectKey := resolveKey(key)
+	_, err = m.client().PutObject(context.Background(), m.Bucket, objectKey, tmp, info.Size(), minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		log.Printf("回源存储失败: %v", err)
+		return
+	}
+	log.Printf("回源存储完成: %s", key)
+}
+
+func finishPull(key string, tmp *os.File) {
+	if tmp != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	pullingMu.Lock()
+	delete(pulling, key)
+	pullingMu.Unlock()
+}
+
+// 客户端写入失败后继续消费数据，保证上游内容完整写入临时文件
+type writerIgnoringErrors struct {
+	w io.Writer
+}
+
+func (w writerIgnoringErrors) Write(p []byte) (int, error) {
+	w.w.Write(p)
+	return len(p), nil
+}
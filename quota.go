@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	quotas        = valueMap{}
+	defaultQuota  = flag.Int64("default-quota", 0, "The bytes each authenticated user may write, 0 means unlimited")
+	quotaStore    = flag.String("quota-usage", "", "Where written bytes per user are persisted, a file path or bucket:key, quotas are disabled when empty")
+	quotaInterval = flag.Duration("quota-interval", time.Minute, "How often written bytes per user are persisted")
+)
+
+func init() {
+	flag.Var(quotas, "quota", "The user=bytes quota of an authenticated user, can be repeated")
+}
+
+var usage struct {
+	sync.Mutex
+	written map[string]int64
+	dirty   bool
+}
+
+type QuotaInfo struct {
+	User      string `json:"user"`
+	Used      int64  `json:"used"`
+	Quota     int64  `json:"quota"` // 0 表示不限制
+	Remaining int64  `json:"remaining,omitempty"`
+}
+
+func quotasEnabled() bool {
+	return *quotaStore != ""
+}
+
+func startQuotas() error {
+	if !quotasEnabled() || usage.written != nil {
+		return nil
+	}
+	written := map[string]int64{}
+	data, err := loadState(*quotaStore)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := json.Unmarshal(data, &written); err != nil {
+			return err
+		}
+	}
+	usage.written = written
+	go func() {
+		for range time.Tick(*quotaInterval) {
+			saveUsage()
+		}
+	}()
+	return nil
+}
+
+func saveUsage() {
+	usage.Lock()
+	if !usage.dirty {
+		usage.Unlock()
+		return
+	}
+	data, err := json.Marshal(usage.written)
+	usage.dirty = false
+	usage.Unlock()
+	if err != nil {
+		log.Printf("配额用量保存失败: %v", err)
+		return
+	}
+	if err := saveState(*quotaStore, data); err != nil {
+		log.Printf("配额用量保存失败: %v", err)
+		usage.Lock()
+		usage.dirty = true
+		usage.Unlock()
+	}
+}
+
+func userQuota(user string) int64 {
+	if v, ok := quotas[user]; ok {
+		if quota, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return quota
+		}
+		log.Printf("配额配置错误: %s=%s", user, v)
+	}
+	return *defaultQuota
+}
+
+func quotaInfo(user string) QuotaInfo {
+	usage.Lock()
+	used := usage.written[user]
+	usage.Unlock()
+	info := QuotaInfo{User: user, Used: used, Quota: userQuota(user)}
+	if info.Quota > 0 {
+		info.Remaining = max(info.Quota-used, 0)
+	}
+	return info
+}
+
+// 会增加存储用量的写操作
+func addsBytes(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodPut, "COPY", "MOVE":
+		return true
+	case http.MethodPost:
+		return r.URL.Query().Has("fetch")
+	}
+	return false
+}
+
+// 写入前检查配额，额度已用完时直接拒绝，实际用量在写入时预留
+func checkQuota(w http.ResponseWriter, r *http.Request) bool {
+	if !quotasEnabled() || !addsBytes(r) {
+		return true
+	}
+	info := quotaInfo(authenticatedUser(r))
+	if info.User == "" || info.Quota <= 0 {
+		return true
+	}
+	if info.Remaining <= 0 || (r.Method == http.MethodPut && r.ContentLength > info.Remaining) {
+		http.Error(w, "507 Insufficient Storage", http.StatusInsufficientStorage)
+		return false
+	}
+	return true
+}
+
+// 预留的配额，写入结束后按实际字节数结算
+type quotaReservation struct {
+	user string
+	size int64
+}
+
+// 在锁内检查剩余额度并预先计入用量，避免并发写入同时通过检查。
+// size 为负（长度未知）时预留全部剩余额度；limit 为可写入的上限，0 表示不限制
+func reserveQuota(r *http.Request, size int64) (res *quotaReservation, limit int64, ok bool) {
+	if !quotasEnabled() {
+		return nil, 0, true
+	}
+	user := authenticatedUser(r)
+	quota := userQuota(user)
+	if user == "" || quota <= 0 {
+		return nil, 0, true
+	}
+	usage.Lock()
+	defer usage.Unlock()
+	remaining := quota - usage.written[user]
+	if size < 0 {
+		size = remaining
+	}
+	if remaining <= 0 || size > remaining {
+		return nil, 0, false
+	}
+	usage.written[user] += size
+	usage.dirty = true
+	return &quotaReservation{user: user, size: size}, size, true
+}
+
+// 按实际写入的字节数结算，写入失败时传 0 归还预留的额度
+func (q *quotaReservation) settle(written int64) {
+	if q == nil || written == q.size {
+		return
+	}
+	usage.Lock()
+	usage.written[q.user] += written - q.size
+	usage.dirty = true
+	usage.Unlock()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"io"
+)
+
+var (
+	readaheadSize   = flag.Int("readahead", 0, "The size in bytes of chunks read ahead from oss while the previous one is written to the client, 0 disables it")
+	readaheadChunks = flag.Int("readahead-chunks", 2, "The number of chunks read ahead")
+)
+
+type chunk struct {
+	data []byte
+	err  error
+}
+
+// 后台持续从后端读取下一块，写给客户端的同时不阻塞后端传输，平滑高延迟链路的吞吐
+type readaheadReader struct {
+	object  io.ReadCloser
+	chunks  chan chunk
+	done    chan struct{}
+	current []byte
+	err     error
+}
+
+// 对象大于一块时启用预读
+func readAhead(object io.ReadCloser, size int64) io.ReadCloser {
+	if *readaheadSize <= 0 || size <= int64(*readaheadSize) {
+		return object
+	}
+	r := &readaheadReader{
+		object: object,
+		chunks: make(chan chunk, max(*readaheadChunks, 1)),
+		done:   make(chan struct{}),
+	}
+	go r.fill()
+	return r
+}
+
+func (r *readaheadReader) fill() {
+	defer close(r.chunks)
+	for {
+		data := make([]byte, *readaheadSize)
+		n, err := io.ReadFull(r.object, data)
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		select {
+		case r.chunks <- chunk{data: data[:n], err: err}:
+		case <-r.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (r *readaheadReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		next, ok := <-r.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		r.current, r.err = next.data, next.err
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// 先通知后台停止再关闭对象，关闭会使后台进行中的读取返回
+func (r *readaheadReader) Close() error {
+	close(r.done)
+	return r.object.Close()
+}
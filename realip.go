@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 可重复指定的 CIDR 参数，单个 IP 视为 /32 或 /128
+type cidrList []*net.IPNet
+
+var (
+	realIPHeader   = flag.String("real-ip-header", "", "The header carrying the client IP set by a CDN or proxy, e.g. CF-Connecting-IP, True-Client-IP or X-Forwarded-For, ignored when empty")
+	trustedProxies cidrList
+)
+
+func init() {
+	flag.Var(&trustedProxies, "trusted-proxy", "The CIDR of a CDN or proxy whose -real-ip-header and X-Forwarded-Proto are trusted, can be repeated")
+}
+
+func (c *cidrList) String() string {
+	var items []string
+	for _, network := range *c {
+		items = append(items, network.String())
+	}
+	return strings.Join(items, ",")
+}
+
+func (c *cidrList) Set(value string) error {
+	if !strings.Contains(value, "/") {
+		if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+			value += "/32"
+		} else {
+			value += "/128"
+		}
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return err
+	}
+	*c = append(*c, network)
+	return nil
+}
+
+func (c cidrList) contains(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 连接来自受信任的 CDN 时从请求头取客户端 IP，否则返回连接地址
+func realIP(r *http.Request, peer string) string {
+	if *realIPHeader == "" || !trustedProxies.contains(peer) {
+		return peer
+	}
+	value := r.Header.Get(*realIPHeader)
+	if value == "" {
+		return peer
+	}
+	if !strings.EqualFold(*realIPHeader, "X-Forwarded-For") {
+		if ip := net.ParseIP(strings.TrimSpace(value)); ip != nil {
+			return ip.String()
+		}
+		return peer
+	}
+	// 从右向左跳过受信任的代理，第一个不受信任的地址为客户端，左侧的值可被客户端伪造
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if i == 0 || !trustedProxies.contains(ip.String()) {
+			return ip.String()
+		}
+	}
+	return peer
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+var reconnectAfter = flag.Int("reconnect-after", 5, "The number of consecutive transport failures after which the oss client is rebuilt, 0 disables rebuilding")
+
+// 可在连续传输失败后重建的 MinIO 客户端
+type backendClient struct {
+	endpoint  string
+	accessKey string
+	secretKey string
+	region    string
+
+	mu        sync.Mutex
+	current   *minio.Client
+	transport *http.Transport
+	local     http.RoundTripper // 进程内后端，不经过网络
+	failures  int
+	stale     bool
+}
+
+func newBackend(endpoint, accessKey, secretKey, region string) (*backendClient, error) {
+	// 按区域选择端点，如 s3.{region}.amazonaws.com
+	endpoint = strings.ReplaceAll(endpoint, "{region}", region)
+	b := &backendClient{endpoint: endpoint, accessKey: accessKey, secretKey: secretKey, region: region}
+	if err := b.rebuild(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 进程内的后端，请求交给 transport 处理
+func newLocalBackend(transport http.RoundTripper) (*backendClient, error) {
+	b := &backendClient{endpoint: "localhost", local: transport}
+	if err := b.rebuild(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// 创建新的客户端与连接池，endpoint 带 https:// 前缀时使用 TLS；调用方需持有锁
+func (b *backendClient) rebuild() error {
+	secure := strings.HasPrefix(b.endpoint, "https://")
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(b.endpoint, "https://"), "http://")
+	var transport *http.Transport
+	roundTripper := b.local
+	if roundTripper == nil {
+		var err error
+		if transport, err = minio.DefaultTransport(secure); err != nil {
+			return err
+		}
+		transport.Proxy = backendProxy()
+		if secure {
+			if transport.TLSClientConfig, err = backendTLSConfig(); err != nil {
+				return err
+			}
+		}
+		roundTripper = &failureCountingTransport{base: transport, owner: b}
+	}
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(b.accessKey, b.secretKey, ""),
+		Secure:    secure,
+		Region:    b.region,
+		Transport: roundTripper,
+	})
+	if err != nil {
+		return err
+	}
+	if b.transport != nil {
+		b.transport.CloseIdleConnections()
+	}
+	b.current, b.transport = client, transport
+	b.failures, b.stale = 0, false
+	return nil
+}
+
+// 当前客户端，被标记失效时在此重建，旧连接上进行中的请求不受影响
+func (b *backendClient) client() *minio.Client {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stale {
+		if err := b.rebuild(); err != nil {
+			log.Printf("客户端重建失败: %v", err)
+		} else {
+			log.Printf("已重建 %s 的客户端", b.endpoint)
+		}
+	}
+	return b.current
+}
+
+func (b *backendClient) recordResult(transport *http.Transport, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// 旧连接池上的结果不再计入
+	if transport != b.transport {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if *reconnectAfter > 0 && b.failures >= *reconnectAfter && !b.stale {
+		log.Printf("%s 连续 %d 次传输失败，将重建客户端", b.endpoint, b.failures)
+		b.stale = true
+	}
+}
+
+// 统计传输层错误（连接失败、DNS 解析失败等），不包括 HTTP 错误状态
+type failureCountingTransport struct {
+	base  *http.Transport
+	owner *backendClient
+}
+
+func (t *failureCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	// 客户端取消的请求不算后端故障
+	if err != nil && req.Context().Err() != nil {
+		return resp, err
+	}
+	t.owner.recordResult(t.base, err != nil)
+	return resp, err
+}
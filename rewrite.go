@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type rewriteRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+	status      int // 非零时以重定向方式返回
+}
+
+// 可重复指定的 "pattern replacement [redirect|permanent]" 参数
+type rewriteRules []rewriteRule
+
+var rewrites rewriteRules
+
+func init() {
+	flag.Var(&rewrites, "rewrite", "The rewrite rule of request path as \"regexp replacement [redirect|permanent]\", can be repeated")
+}
+
+func (rules *rewriteRules) String() string {
+	var items []string
+	for _, rule := range *rules {
+		items = append(items, rule.pattern.String()+" "+rule.replacement)
+	}
+	return strings.Join(items, ",")
+}
+
+func (rules *rewriteRules) Set(value string) error {
+	fields := strings.Fields(value)
+	if len(fields) < 2 || len(fields) > 3 {
+		return fmt.Errorf("invalid value %q, expect \"regexp replacement [redirect|permanent]\"", value)
+	}
+	pattern, err := regexp.Compile(fields[0])
+	if err != nil {
+		return err
+	}
+	rule := rewriteRule{pattern: pattern, replacement: fields[1]}
+	if len(fields) == 3 {
+		switch fields[2] {
+		case "redirect":
+			rule.status = http.StatusFound
+		case "permanent":
+			rule.status = http.StatusMovedPermanently
+		default:
+			return fmt.Errorf("invalid rewrite flag %q", fields[2])
+		}
+	}
+	*rules = append(*rules, rule)
+	return nil
+}
+
+// 按顺序匹配，第一条命中的规则生效
+func (rules rewriteRules) apply(requestPath string) (string, int, bool) {
+	for _, rule := range rules {
+		if rule.pattern.MatchString(requestPath) {
+			return rule.pattern.ReplaceAllString(requestPath, rule.replacement), rule.status, true
+		}
+	}
+	return requestPath, 0, false
+}
+
+// 改写请求路径，返回 false 表示已重定向
+func handleRewrite(w http.ResponseWriter, r *http.Request) bool {
+	target, status, ok := rewrites.apply(r.URL.Path)
+	if !ok {
+		return true
+	}
+	if status != 0 {
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, status)
+		return false
+	}
+	r.URL.Path = target
+	r.URL.RawPath = ""
+	return true
+}
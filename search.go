@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 搜索结果页面模板
+const searchTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Search {{.Query}}</title>
+    {{template "style"}}
+</head>
+<body>
+    <h1>Search "{{.Query}}" in /{{.Prefix}}</h1>
+    <form action="/search">
+        <input type="text" name="q" value="{{.Query}}">
+        <input type="hidden" name="prefix" value="{{.Prefix}}">
+        {{with .Tag}}<input type="hidden" name="tag" value="{{.}}">{{end}}
+        <button type="submit">Search</button>
+    </form>
+    <table>
+        <tr><th>Name</th><th>Size</th><th>Last Modified</th></tr>
+        {{range .Results}}
+        <tr>
+            <td><a href="{{.URL}}">{{.Name}}</a></td>
+            <td>{{.Size}}</td>
+            <td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td>
+        </tr>
+        {{end}}
+    </table>
+    {{if .Truncated}}<p>Only the first {{len .Results}} results are shown.</p>{{end}}
+</body>
+</html>`
+
+var (
+	searchLimit     = flag.Int("search-limit", 1000, "The maximum number of search results")
+	searchScanLimit = flag.Int("search-scan-limit", 100000, "The maximum number of objects scanned by one search, 0 for no limit")
+	searchTmpl      = newPageTemplate("search", searchTemplate)
+)
+
+type SearchResult struct {
+	Query     string     `json:"query"`
+	Prefix    string     `json:"prefix"`
+	Results   []DirEntry `json:"results"`
+	Truncated bool       `json:"truncated"`
+	Tag       string     `json:"tag,omitempty"`
+}
+
+// 按匹配方式比较 key：glob 通配、prefix 前缀，默认不区分大小写的子串
+func searchMatch(mode, query, name string) bool {
+	switch mode {
+	case "glob":
+		matched, _ := path.Match(query, name)
+		if !matched {
+			matched, _ = path.Match(query, path.Base(name))
+		}
+		return matched
+	case "prefix":
+		return strings.HasPrefix(name, query)
+	default:
+		return strings.Contains(strings.ToLower(name), strings.ToLower(query))
+	}
+}
+
+// 在前缀下递归扫描匹配且 visible 的 key，最多返回 limit 个结果，扫描超过 search-scan-limit 个对象时同样截断
+func searchObjects(ctx context.Context, prefix, query, mode string, limit int, visible func(obj minio.ObjectInfo) bool) ([]DirEntry, bool, error) {
+	if mode == "" && strings.ContainsAny(query, "*?[") {
+		mode = "glob"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := []DirEntry{}
+	if query == "" {
+		return results, false, nil
+	}
+	scanned := 0
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, false, obj.Err
+		}
+		if scanned++; *searchScanLimit > 0 && scanned > *searchScanLimit {
+			return results, true, nil
+		}
+		name := strings.TrimPrefix(obj.Key, prefix)
+		if !searchMatch(mode, query, name) || !keyListable(obj.Key) || !visible(obj) {
+			continue
+		}
+		if len(results) >= limit {
+			return results, true, nil
+		}
+		results = append(results, DirEntry{
+			Key:     obj.Key,
+			URL:     keyURL(obj.Key),
+			Name:    name,
+			Size:    formatSize(obj.Size),
+			Bytes:   obj.Size,
+			ModTime: obj.LastModified,
+			IsDir:   strings.HasSuffix(obj.Key, "/"),
+			ETag:    obj.ETag,
+		})
+	}
+	return results, false, nil
+}
+
+// 搜索结果的可见性：可读，且在截断前按标签过滤，避免结果被不匹配的文件占满
+func searchVisible(r *http.Request, tag string) func(obj minio.ObjectInfo) bool {
+	return func(obj minio.ObjectInfo) bool {
+		if !canRead(r, obj.Key) {
+			return false
+		}
+		return tag == "" || objectHasTag(r.Context(), obj.Key, obj.ETag, tag)
+	}
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	prefix := strings.TrimPrefix(r.URL.Query().Get("prefix"), "/")
+	tag := r.URL.Query().Get("tag")
+	if !listingAllowed(prefix) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+	if !checkHome(w, r, prefix, false) {
+		return
+	}
+
+	visible := searchVisible(r, tag)
+	results, truncated, err := searchObjects(r.Context(), prefix, query, r.URL.Query().Get("mode"), *searchLimit, visible)
+	if err != nil {
+		log.Printf("目录列表错误: %v", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if tag != "" {
+		fillTags(r.Context(), results)
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(w).Encode(SearchResult{query, keyURL(prefix), results, truncated, tag})
+		if err != nil {
+			log.Printf("响应写入失败: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = searchTmpl.Execute(w, SearchResult{query, prefix, results, truncated, tag})
+	if err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+var securityLogPath = flag.String("security-log", "", "The file receiving auth failures and rate limit violations in a fixed format for fail2ban, the standard log when empty")
+
+var securityLog struct {
+	sync.Mutex
+	file *os.File
+}
+
+func startSecurityLog() {
+	if *securityLogPath == "" {
+		return
+	}
+	file, err := os.OpenFile(*securityLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		log.Fatal("安全日志打开失败: ", err)
+	}
+	securityLog.file = file
+}
+
+// 每行格式固定为：
+//
+//	2006-01-02T15:04:05Z07:00 bucket2http security: reason=<reason> ip=<ip> path="<path>"
+//
+// reason 为 bad-credentials 或 rate-limited，对应的 fail2ban 规则：
+//
+//	failregex = bucket2http security: reason=\S+ ip=<HOST>
+func logSecurity(r *http.Request, reason string) {
+	line := fmt.Sprintf("bucket2http security: reason=%s ip=%s path=%q", reason, clientIP(r), r.URL.Path)
+	securityLog.Lock()
+	defer securityLog.Unlock()
+	if securityLog.file == nil {
+		log.Println(line)
+		return
+	}
+	if _, err := fmt.Fprintf(securityLog.file, "%s %s\n", time.Now().Format(time.RFC3339), line); err != nil {
+		log.Printf("安全日志写入失败: %v", err)
+	}
+}
+
+// 只记录提供了错误凭据的请求，浏览器首次请求不带凭据属于正常情况
+func logAuthFailure(r *http.Request) {
+	if _, _, ok := r.BasicAuth(); ok {
+		logSecurity(r, "bad-credentials")
+	}
+}
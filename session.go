@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const sessionCookie = "bucket2http_session"
+
+var (
+	sessionKeyPath = flag.String("session-key-file", "", "The file of keys signing session cookies issued after login, one per line, the first signs and all verify so keys can be rotated, reloaded on change, sessions are disabled when empty")
+	sessionTTL     = flag.Duration("session-ttl", 12*time.Hour, "How long a session cookie stays valid")
+	revokedStore   = flag.String("session-revocations", "", "Where sessions ended by logout are persisted until they expire, a file path or bucket:key, kept only in memory when empty")
+)
+
+// 已注销的会话：签名 -> 过期时间（Unix 秒），过期后移除
+var revoked struct {
+	sync.Mutex
+	sessions map[string]int64
+}
+
+// 会话签名密钥，文件修改后自动重新加载
+type sessionKeyring struct {
+	mu      sync.RWMutex
+	keys    [][]byte
+	modTime time.Time
+}
+
+var sessionKeys = &sessionKeyring{}
+
+func (k *sessionKeyring) load(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	k.mu.RLock()
+	unchanged := info.ModTime().Equal(k.modTime)
+	k.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var keys [][]byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(line) < 32 {
+			return errors.New("session keys must be at least 32 bytes")
+		}
+		keys = append(keys, []byte(line))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("no session key found")
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.modTime = info.ModTime()
+	k.mu.Unlock()
+	log.Printf("会话密钥已加载: %d 个", len(keys))
+	return nil
+}
+
+func (k *sessionKeyring) watch(path string, interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := k.load(path); err != nil {
+			log.Printf("会话密钥加载失败: %v", err)
+		}
+	}
+}
+
+func startSessions() {
+	if *sessionKeyPath == "" {
+		return
+	}
+	if err := sessionKeys.load(*sessionKeyPath); err != nil {
+		log.Fatal("会话密钥加载失败: ", err)
+	}
+	go sessionKeys.watch(*sessionKeyPath, 5*time.Second)
+}
+
+// 读取已注销的会话，与下载计数一样在后端就绪后加载
+func startRevocations() error {
+	revoked.Lock()
+	defer revoked.Unlock()
+	if revoked.sessions != nil {
+		return nil
+	}
+	revoked.sessions = map[string]int64{}
+	if *sessionKeyPath == "" || *revokedStore == "" {
+		return nil
+	}
+	data, err := loadState(*revokedStore)
+	if err != nil || data == nil {
+		return err
+	}
+	return json.Unmarshal(data, &revoked.sessions)
+}
+
+func sessionRevoked(signature string) bool {
+	revoked.Lock()
+	defer revoked.Unlock()
+	_, ok := revoked.sessions[signature]
+	return ok
+}
+
+// 注销会话，同时清理已过期的记录
+func revokeSession(signature string, expires int64) {
+	revoked.Lock()
+	if revoked.sessions == nil {
+		revoked.sessions = map[string]int64{}
+	}
+	now := time.Now().Unix()
+	for sig, exp := range revoked.sessions {
+		if exp < now {
+			delete(revoked.sessions, sig)
+		}
+	}
+	revoked.sessions[signature] = expires
+	data, err := json.Marshal(revoked.sessions)
+	revoked.Unlock()
+	if *revokedStore == "" {
+		return
+	}
+	if err == nil {
+		err = saveState(*revokedStore, data)
+	}
+	if err != nil {
+		log.Printf("会话注销记录保存失败: %v", err)
+	}
+}
+
+func sessionMAC(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Cookie 内容为 base64(用户名).过期时间.签名
+func signSession(user string, expires time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(user)) + "." + strconv.FormatInt(expires.Unix(), 10)
+	sessionKeys.mu.RLock()
+	defer sessionKeys.mu.RUnlock()
+	return payload + "." + sessionMAC(sessionKeys.keys[0], payload)
+}
+
+// 会话 Cookie 对应的用户，无效、过期、已注销或用户已被删除时返回空
+func sessionUser(r *http.Request) string {
+	user, _, _ := verifySession(r)
+	return user
+}
+
+// 校验会话 Cookie，返回用户、签名与过期时间
+func verifySession(r *http.Request) (string, string, int64) {
+	if *sessionKeyPath == "" {
+		return "", "", 0
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", "", 0
+	}
+	i := strings.LastIndex(cookie.Value, ".")
+	if i < 0 {
+		return "", "", 0
+	}
+	payload, signature := cookie.Value[:i], cookie.Value[i+1:]
+	encodedUser, expiresText, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", "", 0
+	}
+
+	valid := false
+	sessionKeys.mu.RLock()
+	for _, key := range sessionKeys.keys {
+		if hmac.Equal([]byte(signature), []byte(sessionMAC(key, payload))) {
+			valid = true
+			break
+		}
+	}
+	sessionKeys.mu.RUnlock()
+	if !valid || sessionRevoked(signature) {
+		return "", "", 0
+	}
+	expires, err := strconv.ParseInt(expiresText, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return "", "", 0
+	}
+	user, err := base64.RawURLEncoding.DecodeString(encodedUser)
+	if err != nil {
+		return "", "", 0
+	}
+	if _, ok := writeUsers[string(user)]; !ok && !htpasswd.has(string(user)) {
+		return "", "", 0
+	}
+	return string(user), signature, expires
+}
+
+// 通过密码登录成功后签发会话，之后浏览器的请求无需再次认证
+func issueSession(w http.ResponseWriter, r *http.Request, user string) {
+	// Bearer 令牌由客户端每次携带，无需会话
+	if *sessionKeyPath == "" || sessionUser(r) == user || strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		return
+	}
+	expires := time.Now().Add(*sessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    signSession(user, expires),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// 注销会话并清除 Cookie，返回 401 使浏览器丢弃缓存的 Basic 认证信息。
+// 只清除 Cookie 时，被复制走的 Cookie 在过期前仍然有效
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	if user, signature, expires := verifySession(r); user != "" {
+		revokeSession(signature, expires)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   requestScheme(r) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Error(w, "401 Unauthorized: logged out", http.StatusUnauthorized)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	shutdownDelay      = flag.Duration("shutdown-delay", 0, "How long to keep answering new requests with 503 and Connection: close after SIGTERM before the listeners close, so load balancers can take the instance out")
+	shutdownRetryAfter = flag.Duration("shutdown-retry-after", 0, "The Retry-After sent with 503 responses while shutting down, omitted when 0")
+	shutdownTimeout    = flag.Duration("shutdown-timeout", 30*time.Second, "How long in-flight requests may take to finish during shutdown")
+	finishDownloads    = flag.Bool("shutdown-finish-downloads", true, "Let in-flight downloads finish during shutdown, otherwise they are aborted when the listeners close")
+	draining           atomic.Bool
+)
+
+// 关闭过程中拒绝新请求，并要求客户端断开连接后重试其他实例
+func withDrain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !draining.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Connection", "close")
+		if *shutdownRetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(shutdownRetryAfter.Seconds())))
+		}
+		http.Error(w, "503 Service Unavailable", http.StatusServiceUnavailable)
+	})
+}
+
+// 优雅关闭：先拒绝新请求，再关闭监听并等待进行中的请求结束，abort 取消所有请求的 ctx
+func shutdown(server *http.Server, abort context.CancelFunc) {
+	log.Println("开始关闭服务")
+	draining.Store(true)
+	time.Sleep(*shutdownDelay)
+	if !*finishDownloads {
+		abort()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("等待请求结束超时: %v", err)
+		server.Close()
+	}
+	// 缓冲中的审计记录
+	flushAudit()
+	// 尚未发送的下载事件
+	if *webhookURL != "" {
+		flushWebhook()
+	}
+	// 尚未持久化的下载计数与配额用量
+	if countersEnabled() {
+		saveCounts()
+	}
+	if quotasEnabled() {
+		saveUsage()
+	}
+	log.Println("服务已关闭")
+}
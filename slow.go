@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	slowFirstByte = flag.Duration("slow-first-byte", 0, "Log requests whose first byte takes longer than this, 0 disables it")
+	slowRequest   = flag.Duration("slow-request", 0, "Log requests taking longer than this in total, 0 disables it")
+)
+
+func init() {
+	onPostResponse(logSlowRequest)
+}
+
+// 首字节与总耗时分别判断，慢下载不一定是后端慢
+func logSlowRequest(r *http.Request, resp *ResponseInfo) {
+	var reason string
+	switch {
+	case *slowFirstByte > 0 && resp.FirstByte > *slowFirstByte:
+		reason = "首字节"
+	case *slowRequest > 0 && resp.Duration > *slowRequest:
+		reason = "总耗时"
+	default:
+		return
+	}
+	log.Printf("慢请求(%s): %s %s 状态 %d 首字节 %s 总耗时 %s 字节 %d 客户端 %s",
+		reason, r.Method, r.URL.Path, resp.Status, resp.FirstByte.Round(time.Millisecond), resp.Duration.Round(time.Millisecond), resp.Bytes, clientIP(r))
+}
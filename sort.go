@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"sort"
+	"strings"
+)
+
+var dirsFirst = flag.Bool("dirs-first", true, "List directories before files")
+
+// 按名称自然排序（file2 在 file10 之前），目录排在文件之前
+func sortEntries(entries []DirEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if *dirsFirst && entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return naturalLess(entries[i].Name, entries[j].Name)
+	})
+}
+
+// 数字部分按数值比较，其余部分按字符比较
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ca, cb := a[0], b[0]
+		if isDigit(ca) && isDigit(cb) {
+			na, restA := digitRun(a)
+			nb, restB := digitRun(b)
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(ta) != len(tb) {
+				return len(ta) < len(tb)
+			}
+			if ta != tb {
+				return ta < tb
+			}
+			// 数值相同时前导零少的在前
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			a, b = restA, restB
+			continue
+		}
+		if ca != cb {
+			return ca < cb
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func digitRun(s string) (string, string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
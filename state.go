@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 读取持久化的状态，location 为文件路径或 bucket:key，不存在时返回 nil
+func loadState(location string) ([]byte, error) {
+	if key, ok := strings.CutPrefix(location, "bucket:"); ok {
+		m, objectKey := resolveKey(strings.TrimPrefix(key, "/"))
+		object, _, _, err := getObject(context.Background(), m, objectKey, minio.GetObjectOptions{})
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer object.Close()
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(object)
+		return buf.Bytes(), err
+	}
+	data, err := os.ReadFile(location)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func saveState(location string, data []byte) error {
+	if key, ok := strings.CutPrefix(location, "bucket:"); ok {
+		m, objectKey := resolveKey(strings.TrimPrefix(key, "/"))
+		_, err := m.client().PutObject(context.Background(), m.Bucket, objectKey, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+			ContentType: "application/json",
+		})
+		return err
+	}
+	// 先写临时文件再改名，避免中途退出留下损坏的文件
+	tmp := location + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, location)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	indexObjectsOnce     = flag.Bool("write-index-once", false, "Render the listing of every directory, upload it as index.html into the bucket and exit")
+	indexObjectsInterval = flag.Duration("write-index-interval", 0, "How often index.html objects are regenerated while serving, 0 disables it")
+)
+
+const indexObjectName = "index.html"
+
+// 生成的索引带有此用户元数据，没有的 index.html 视为用户自己的页面，不会覆盖
+const indexGeneratedBy, indexGenerator = "Generated-By", "bucket2http"
+
+// 定期生成 index.html 对象
+func startIndexObjects() {
+	if *indexObjectsInterval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			if backendReady.Load() {
+				if err := writeIndexObjects(context.Background()); err != nil {
+					log.Printf("目录索引生成失败: %v", err)
+				}
+			}
+			time.Sleep(*indexObjectsInterval)
+		}
+	}()
+}
+
+// 为所有挂载点中的每个目录生成 index.html，使存储桶直接通过静态网站托管或 CDN 访问时也可浏览
+func writeIndexObjects(ctx context.Context) error {
+	count := 0
+	for _, m := range mounts {
+		prefixes := map[string]bool{m.Path: true}
+		for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: m.Path, Recursive: true}) {
+			if obj.Err != nil {
+				return obj.Err
+			}
+			for dir := path.Dir(strings.TrimSuffix(obj.Key, "/")); dir != "." && dir+"/" != m.Path; dir = path.Dir(dir) {
+				prefixes[dir+"/"] = true
+			}
+		}
+		for prefix := range prefixes {
+			written, err := writeIndexObject(ctx, prefix)
+			if err != nil {
+				return err
+			}
+			if written {
+				count++
+			}
+		}
+	}
+	log.Printf("已生成 %d 个目录索引", count)
+	return nil
+}
+
+// 生成单个目录的索引，禁止列出或限制读取的目录跳过
+func writeIndexObject(ctx context.Context, prefix string) (bool, error) {
+	if !listingAllowed(prefix) {
+		return false, nil
+	}
+	if *accessFileName != "" {
+		rules, err := findAccessRules(ctx, prefix)
+		if err != nil {
+			return false, err
+		}
+		if rules != nil && rules.read != nil && !slices.Contains(rules.read, "*") {
+			return false, nil
+		}
+	}
+	m, objectKey := resolveKey(prefix + indexObjectName)
+	objInfo, err := statObject(ctx, m, objectKey, minio.StatObjectOptions{})
+	if err == nil && objInfo.UserMetadata[indexGeneratedBy] != indexGenerator {
+		return false, nil
+	}
+	if err != nil && minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return false, err
+	}
+
+	// 只为公开可读的目录生成，无需逐个检查
+	listing, err := listDirectory(ctx, prefix, false, func(string) bool { return true })
+	if err != nil || listing == nil {
+		return false, err
+	}
+
+	// 生成的索引本身不出现在列表中
+	entries := slices.DeleteFunc(listing.Entries, func(e DirEntry) bool {
+		return !e.IsDir && e.Name == indexObjectName
+	})
+	if prefix != findMount(prefix).Path {
+		entries = append([]DirEntry{{Name: "..", Size: "-", IsDir: true, Icon: getFileIcon("dir")}}, entries...)
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, listingPage{
+		Path:      listing.Path,
+		Entries:   entries,
+		Columns:   requestColumns(*showClass),
+		Files:     listing.Files,
+		Dirs:      listing.Dirs,
+		TotalSize: formatSize(listing.TotalSize),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	_, err = m.client().PutObject(ctx, m.Bucket, objectKey, &buf, int64(buf.Len()), minio.PutObjectOptions{
+		ContentType:  "text/html; charset=utf-8",
+		UserMetadata: map[string]string{indexGeneratedBy: indexGenerator},
+	})
+	return err == nil, err
+}
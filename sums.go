@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 缓存条目上限，超出后整体清空
+const maxSumCacheEntries = 100000
+
+var (
+	sumsMaxBytes = flag.Int64("sums-max-bytes", 1<<30, "The maximum total size of uncached files hashed for one ?sums manifest, 0 for no limit")
+
+	// key + ETag -> SHA256，对象内容变化后 ETag 改变，旧条目自然失效
+	sumCache   = map[string]string{}
+	sumCacheMu sync.Mutex
+)
+
+func cachedSHA256(key, etag string) (string, bool) {
+	sumCacheMu.Lock()
+	defer sumCacheMu.Unlock()
+	sum, ok := sumCache[key+"\x00"+etag]
+	return sum, ok
+}
+
+func objectSHA256(ctx context.Context, key, etag string) (string, error) {
+	if sum, ok := cachedSHA256(key, etag); ok {
+		return sum, nil
+	}
+
+	m, objectKey := resolveKey(key)
+	object, _, _, err := getObject(ctx, m, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return "", err
+	}
+	defer object.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, object); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	sumCacheMu.Lock()
+	if len(sumCache) >= maxSumCacheEntries {
+		sumCache = map[string]string{}
+	}
+	sumCache[key+"\x00"+etag] = sum
+	sumCacheMu.Unlock()
+	return sum, nil
+}
+
+// 生成目录下文件的 SHA256SUMS 清单，跳过规则对象、被过滤、无权读取与归档存储的文件
+func handleSums(w http.ResponseWriter, r *http.Request, prefix string) {
+	if r.URL.Query().Get("sums") != "sha256" {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if !listingAllowed(prefix) {
+		http.Error(w, "403 Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// 先列出全部文件，未缓存的总大小超出上限时不开始计算
+	ctx := r.Context()
+	var objects []minio.ObjectInfo
+	var uncached int64
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			log.Printf("目录列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if strings.HasSuffix(obj.Key, "/") || !zipIncluded(r, obj.Key, obj) {
+			continue
+		}
+		objects = append(objects, obj)
+		if _, ok := cachedSHA256(obj.Key, obj.ETag); !ok {
+			uncached += obj.Size
+		}
+	}
+	if len(objects) == 0 {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	if *sumsMaxBytes > 0 && uncached > *sumsMaxBytes {
+		http.Error(w, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var lines []string
+	for _, obj := range objects {
+		sum, err := objectSHA256(ctx, obj.Key, obj.ETag)
+		// 列表未标明存储类型的归档对象同样跳过
+		if minio.ToErrorResponse(err).Code == "InvalidObjectState" {
+			continue
+		}
+		if err != nil {
+			// 客户端已断开时不再记录
+			if ctx.Err() == nil {
+				log.Printf("校验和计算失败: %v", err)
+				http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			}
+			return
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s\n", sum, path.Base(obj.Key)))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="SHA256SUMS"`)
+	io.WriteString(w, strings.Join(lines, ""))
+}
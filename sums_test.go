@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSums(t *testing.T) {
+	setFlag(t, "deny-ext", ".bin")
+	base := startTestGateway(t, map[string]string{
+		"d/a.txt":       "a",
+		"d/b.bin":       "b",
+		"d/.access":     "read: *",
+		"d/sub/c.txt":   "c",
+		"hidden/d.txt":  "d",
+		"private/e.txt": "ee",
+	})
+
+	_, body := doRequest(t, http.MethodGet, base+"/d/?sums=sha256", "", nil)
+	// sha256("a")
+	want := "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  a.txt\n"
+	if body != want {
+		t.Errorf("manifest:\n%s\nwant:\n%s", body, want)
+	}
+
+	old := hiddenDirs
+	hiddenDirs = stringList{"/hidden/**"}
+	t.Cleanup(func() { hiddenDirs = old })
+	if resp, body := doRequest(t, http.MethodGet, base+"/hidden/?sums=sha256", "", nil); resp.StatusCode != http.StatusForbidden || strings.Contains(body, "d.txt") {
+		t.Errorf("manifest of an unlisted directory: %s", resp.Status)
+	}
+
+	setFlag(t, "sums-max-bytes", "1")
+	if resp, _ := doRequest(t, http.MethodGet, base+"/private/?sums=sha256", "", nil); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("manifest above -sums-max-bytes: %s", resp.Status)
+	}
+}
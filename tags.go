@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 并发获取标签的请求数
+	tagWorkers = 8
+	// 缓存条目上限，超出后整体清空
+	maxTagCacheEntries = 100000
+)
+
+type tagCacheEntry struct {
+	tags    map[string]string
+	expires time.Time
+}
+
+var (
+	tagCacheTTL = flag.Duration("tag-cache-ttl", 5*time.Minute, "How long object tags are cached")
+
+	// key + ETag -> 标签，标签修改不会改变 ETag，因此同时设置过期时间
+	tagCache   = map[string]tagCacheEntry{}
+	tagCacheMu sync.Mutex
+)
+
+func objectTags(ctx context.Context, key string) (map[string]string, error) {
+	m, objectKey := resolveKey(key)
+	t, err := getObjectTagging(ctx, m, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	return t.ToMap(), nil
+}
+
+// 带缓存地获取标签，etag 为空时不使用缓存
+func cachedObjectTags(ctx context.Context, key, etag string) (map[string]string, error) {
+	if etag == "" {
+		return objectTags(ctx, key)
+	}
+	cacheKey := key + "\x00" + etag
+	tagCacheMu.Lock()
+	entry, ok := tagCache[cacheKey]
+	tagCacheMu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.tags, nil
+	}
+
+	tags, err := objectTags(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	tagCacheMu.Lock()
+	if len(tagCache) >= maxTagCacheEntries {
+		tagCache = map[string]tagCacheEntry{}
+	}
+	tagCache[cacheKey] = tagCacheEntry{tags: tags, expires: time.Now().Add(*tagCacheTTL)}
+	tagCacheMu.Unlock()
+	return tags, nil
+}
+
+// 标签是否满足 key=value 过滤条件
+func tagMatches(tags map[string]string, filter string) bool {
+	name, value, _ := strings.Cut(filter, "=")
+	v, ok := tags[name]
+	return ok && v == value
+}
+
+// 单个对象是否带有指定标签，目录始终满足
+func objectHasTag(ctx context.Context, key, etag, filter string) bool {
+	if strings.HasSuffix(key, "/") {
+		return true
+	}
+	tags, err := cachedObjectTags(ctx, key, etag)
+	return err == nil && tagMatches(tags, filter)
+}
+
+// 按 key=value 过滤文件，目录保留
+func filterByTag(ctx context.Context, entries []DirEntry, filter string) []DirEntry {
+	fillTags(ctx, entries)
+	filtered := []DirEntry{}
+	for _, entry := range entries {
+		if entry.IsDir || tagMatches(entry.Tags, filter) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered
+}
+
+// 为列表中的文件填充标签，单个文件获取失败时忽略
+func fillTags(ctx context.Context, entries []DirEntry) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, tagWorkers)
+	for i := range entries {
+		if entries[i].IsDir {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(entry *DirEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if tags, err := cachedObjectTags(ctx, entry.Key, entry.ETag); err == nil && len(tags) > 0 {
+				entry.Tags = tags
+			}
+		}(&entries[i])
+	}
+	wg.Wait()
+}
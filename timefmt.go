@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var (
+	relativeTime       = flag.Bool("relative-time", false, "Show last modified times as \"3 hours ago\" with the exact time in a tooltip")
+	mountRelativeTimes = valueMap{}
+)
+
+func init() {
+	flag.Var(mountRelativeTimes, "mount-relative-time", "Override -relative-time for a mount as path=true|false, can be repeated")
+}
+
+// 挂载点是否使用相对时间
+func mountRelativeTime(name string) bool {
+	if v, ok := mountRelativeTimes[name]; ok {
+		enabled, err := strconv.ParseBool(v)
+		if err == nil {
+			return enabled
+		}
+	}
+	return *relativeTime
+}
+
+// 将时间格式化为 "3 hours ago" 形式
+func formatRelativeTime(t time.Time) string {
+	d := time.Since(t)
+	if d < 0 {
+		d = 0
+	}
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"year", 365 * 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+		{"day", 24 * time.Hour},
+		{"hour", time.Hour},
+		{"minute", time.Minute},
+	}
+	for _, unit := range units {
+		if n := int(d / unit.size); n >= 1 {
+			if n == 1 {
+				return "1 " + unit.name + " ago"
+			}
+			return fmt.Sprintf("%d %ss ago", n, unit.name)
+		}
+	}
+	return "just now"
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+var (
+	statTimeout = flag.Duration("stat-timeout", 10*time.Second, "The timeout of a stat request to oss, 0 disables it")
+	listTimeout = flag.Duration("list-timeout", 30*time.Second, "The longest wait for the next entry of a listing from oss, 0 disables it")
+	getTimeout  = flag.Duration("get-timeout", 30*time.Second, "The longest wait for the first byte of an object from oss, 0 disables it")
+)
+
+// 在 d 内未被重置时取消 ctx，取消后 minio 不再重试
+type watchdog struct {
+	d      time.Duration
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+func newWatchdog(ctx context.Context, d time.Duration) (context.Context, *watchdog) {
+	ctx, cancel := context.WithCancel(ctx)
+	w := &watchdog{d: d, cancel: cancel}
+	if d > 0 {
+		w.timer = time.AfterFunc(d, cancel)
+	}
+	return ctx, w
+}
+
+func (w *watchdog) reset() {
+	if w.timer != nil {
+		w.timer.Reset(w.d)
+	}
+}
+
+// 停止计时，ctx 保持有效直到 release
+func (w *watchdog) disarm() {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+func (w *watchdog) release() {
+	w.disarm()
+	w.cancel()
+}
+
+func statObject(ctx context.Context, m *Mount, objectKey string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	ctx, wd := newWatchdog(ctx, *statTimeout)
+	defer wd.release()
+	start := time.Now()
+	objInfo, err := m.client().StatObject(ctx, m.Bucket, objectKey, opts)
+	observeBackend("StatObject", m, start, err)
+	return objInfo, err
+}
+
+// 标签与文件信息一样是一次简单查询，使用 stat-timeout
+func getObjectTagging(ctx context.Context, m *Mount, objectKey string) (*tags.Tags, error) {
+	ctx, wd := newWatchdog(ctx, *statTimeout)
+	defer wd.release()
+	start := time.Now()
+	t, err := m.client().GetObjectTagging(ctx, m.Bucket, objectKey, minio.GetObjectTaggingOptions{})
+	observeBackend("GetObjectTagging", m, start, err)
+	return t, err
+}
+
+// 获取对象内容，get-timeout 只限制收到响应头之前的等待
+func getObject(ctx context.Context, m *Mount, objectKey string, opts minio.GetObjectOptions) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	ctx, wd := newWatchdog(ctx, *getTimeout)
+	start := time.Now()
+	object, objInfo, header, err := minio.Core{Client: m.client()}.GetObject(ctx, m.Bucket, objectKey, opts)
+	observeBackend("GetObject", m, start, err)
+	if err != nil {
+		wd.release()
+		return nil, objInfo, header, err
+	}
+	wd.disarm()
+	return &releasingReader{ReadCloser: object, wd: wd}, objInfo, header, nil
+}
+
+type releasingReader struct {
+	io.ReadCloser
+	wd *watchdog
+}
+
+func (r *releasingReader) Close() error {
+	defer r.wd.release()
+	return r.ReadCloser.Close()
+}
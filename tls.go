@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+var (
+	tlsCert           = flag.String("tls-cert", "", "The certificate file of HTTPS service, HTTPS is disabled when empty")
+	tlsKey            = flag.String("tls-key", "", "The private key file of HTTPS service")
+	clientCA          = flag.String("client-ca", "", "The CA file used to verify client certificates")
+	requireClientCert = flag.Bool("require-client-cert", false, "Reject HTTPS clients without a valid certificate")
+	backendCA         = flag.String("backend-ca-file", "", "The CA file trusted in addition to system CAs when connecting to oss over TLS")
+	backendInsecure   = flag.Bool("backend-insecure-skip-verify", false, "Skip verifying the certificate of oss, only for testing with self-signed certificates")
+	certUsers         = valueMap{}
+)
+
+func init() {
+	flag.Var(certUsers, "cert-user", "The subject=user mapping from client certificate common name to user, can be repeated")
+}
+
+func tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if *clientCA == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(*clientCA)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", *clientCA)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	if *requireClientCert {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// 连接后端使用的 TLS 配置，在系统 CA 之外信任指定的 CA
+func backendTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if *backendInsecure {
+		log.Println("警告: 已关闭后端证书校验 (-backend-insecure-skip-verify)，连接可能被中间人窃听或篡改，请勿在生产环境使用")
+		config.InsecureSkipVerify = true
+		return config, nil
+	}
+	if *backendCA == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(*backendCA)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", *backendCA)
+	}
+	config.RootCAs = pool
+	return config, nil
+}
+
+// 已验证的客户端证书对应的用户，未映射时使用证书的 CN
+func certUser(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	if user, ok := certUsers[cn]; ok {
+		return user
+	}
+	return cn
+}
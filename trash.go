@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 回收站页面模板
+const trashTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Trash of {{.Path}}</title>
+    {{template "style"}}
+</head>
+<body>
+    <h1>Trash of {{.Path}}</h1>
+    <table>
+        <tr><th>Name</th><th>Deleted</th><th></th></tr>
+        {{range .Entries}}
+        <tr>
+            <td>{{.Name}}</td>
+            <td>{{.ModTime.Format "2006-01-02 15:04:05"}}</td>
+            <td><button onclick="restore({{.URL}})">Restore</button></td>
+        </tr>
+        {{end}}
+    </table>
+    <script>
+        async function restore(url) {
+            const resp = await fetch(url + '?restore', {method: 'POST', headers: {'X-CSRF-Token': {{.CSRF}}}});
+            if (!resp.ok) {
+                alert('Restore ' + url + ': ' + resp.status);
+            }
+            location.reload();
+        }
+    </script>
+</body>
+</html>`
+
+var (
+	trashRetention = flag.Duration("trash-retention", 30*24*time.Hour, "How long deleted objects stay visible in the trash")
+	trashTmpl      = newPageTemplate("trash", trashTemplate)
+)
+
+// key 所在存储桶是否开启了版本控制
+func versioningEnabled(ctx context.Context, key string) bool {
+	m, _ := resolveKey(key)
+	config, err := m.client().GetBucketVersioning(ctx, m.Bucket)
+	if err != nil {
+		log.Printf("版本控制状态获取失败: %v", err)
+		return false
+	}
+	return config.Enabled()
+}
+
+// 列出目录下最近被删除（最新版本为删除标记）的文件
+func handleTrash(w http.ResponseWriter, r *http.Request, prefix string) {
+	ctx := context.Background()
+	if !versioningEnabled(ctx, prefix) {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	cutoff := time.Now().Add(-*trashRetention)
+	var entries []DirEntry
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithVersions: true,
+	}) {
+		if obj.Err != nil {
+			log.Printf("版本列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if !obj.IsDeleteMarker || !obj.IsLatest || obj.LastModified.Before(cutoff) || !canRead(r, obj.Key) {
+			continue
+		}
+		entries = append(entries, DirEntry{
+			URL:     keyURL(obj.Key),
+			Name:    strings.TrimPrefix(obj.Key, prefix),
+			ModTime: obj.LastModified,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := trashTmpl.Execute(w, struct {
+		Path    string
+		Entries []DirEntry
+		CSRF    string
+	}{
+		Path:    keyURL(prefix),
+		Entries: entries,
+		CSRF:    csrfToken(w, r),
+	})
+	if err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
+
+// 删除最新的删除标记，使上一个版本重新成为当前版本
+func handleRestore(w http.ResponseWriter, key string) {
+	ctx := context.Background()
+	if key == "" || !versioningEnabled(ctx, key) {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+
+	for obj := range listObjects(ctx, minio.ListObjectsOptions{
+		Prefix:       key,
+		Recursive:    true,
+		WithVersions: true,
+	}) {
+		if obj.Err != nil {
+			log.Printf("版本列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if obj.Key != key || !obj.IsLatest {
+			continue
+		}
+		if !obj.IsDeleteMarker {
+			break
+		}
+		m, objectKey := resolveKey(key)
+		err := m.client().RemoveObject(ctx, m.Bucket, objectKey, minio.RemoveObjectOptions{VersionID: obj.VersionID})
+		if err != nil {
+			log.Printf("文件恢复失败: %v", err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Error(w, "404 Not Found", http.StatusNotFound)
+}
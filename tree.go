@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// 树形视图模板，子目录展开时通过 ?format=json 按需加载
+const treeTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Tree of {{.Path}}</title>
+    {{template "style"}}
+    <style>
+        ul {
+            list-style: none;
+            padding-left: 18px;
+            margin: 0;
+        }
+        li {
+            line-height: 1.6;
+        }
+        .toggle {
+            display: inline-block;
+            width: 14px;
+            color: #666;
+            cursor: pointer;
+        }
+        .size {
+            margin-left: 8px;
+            color: #999;
+        }
+    </style>
+</head>
+<body>
+    <h1>Tree of {{.Path}}</h1>
+    <p><a href="?">List view</a></p>
+    <ul id="tree"></ul>
+    <script>
+        function formatSize(size) {
+            const units = ['B', 'KiB', 'MiB', 'GiB', 'TiB'];
+            let i = 0;
+            for (; size >= 1024 && i < units.length - 1; i++) {
+                size /= 1024;
+            }
+            return (i ? size.toFixed(1) : size) + ' ' + units[i];
+        }
+        function render(list, entries) {
+            for (const e of entries) {
+                const li = document.createElement('li');
+                const toggle = document.createElement('span');
+                toggle.className = 'toggle';
+                const link = document.createElement('a');
+                link.href = e.url;
+                link.textContent = e.name + (e.isDir ? '/' : '');
+                li.append(toggle, link);
+                if (e.isDir) {
+                    toggle.textContent = '▸';
+                    toggle.onclick = () => expand(li, toggle, e.url);
+                } else {
+                    const size = document.createElement('span');
+                    size.className = 'size';
+                    size.textContent = formatSize(e.size);
+                    li.append(size);
+                }
+                list.append(li);
+            }
+        }
+        async function expand(li, toggle, url) {
+            const loaded = li.querySelector('ul');
+            if (loaded) {
+                loaded.hidden = !loaded.hidden;
+                toggle.textContent = loaded.hidden ? '▸' : '▾';
+                return;
+            }
+            toggle.textContent = '…';
+            const resp = await fetch(url + '?format=json');
+            if (!resp.ok) {
+                toggle.textContent = '▸';
+                alert(url + ': ' + resp.status);
+                return;
+            }
+            const list = document.createElement('ul');
+            render(list, (await resp.json()).entries);
+            li.append(list);
+            toggle.textContent = '▾';
+        }
+        render(document.getElementById('tree'), {{.Entries}});
+    </script>
+</body>
+</html>`
+
+var treeTmpl = newPageTemplate("tree", treeTemplate)
+
+func writeTreeView(w http.ResponseWriter, listing *Listing) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := treeTmpl.Execute(w, listing); err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	readOnly           = flag.Bool("read-only", true, "Reject every method except GET and HEAD, must be disabled for -write to take effect")
+	writable           = flag.Bool("write", false, "Allow uploading objects through PUT")
+	multipartThreshold = flag.Int64("multipart-threshold", 64<<20, "The upload size in bytes above which multipart upload is used")
+	partSize           = flag.Uint64("part-size", 16<<20, "The part size in bytes of multipart upload")
+	uploadThreads      = flag.Uint("upload-threads", 4, "The number of parts uploaded in parallel")
+)
+
+// 只读模式下在路由之前拒绝所有写方法
+func withReadOnly(next http.Handler) http.Handler {
+	if !*readOnly {
+		return next
+	}
+	if *writable {
+		log.Println("已指定 -write，但 -read-only 仍开启，写操作将被拒绝")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func handleWrite(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodPut:
+		handleUpload(w, r, key)
+	case http.MethodPost:
+		switch {
+		case r.URL.Query().Has("restore"):
+			if checkUser(w, r) {
+				handleRestore(w, key)
+			}
+		case r.URL.Query().Has("thaw"):
+			handleThaw(w, r, key)
+		case r.URL.Query().Has("fetch"):
+			handleFetch(w, r, key)
+		default:
+			http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		}
+	case http.MethodDelete:
+		handleDelete(w, r, key)
+	case "MKCOL":
+		handleMkdir(w, key)
+	case "COPY":
+		handleCopy(w, r, key)
+	case "MOVE":
+		handleMove(w, r, key)
+	default:
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleUpload(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" || strings.HasSuffix(key, "/") {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = getContentType(key)
+	}
+	opts := minio.PutObjectOptions{ContentType: contentType}
+
+	// 大文件（或长度未知）走并行分片上传，任一分片失败时 minio-go 会中止整个上传
+	size := r.ContentLength
+	if size < 0 || size >= *multipartThreshold {
+		opts.PartSize = *partSize
+		opts.NumThreads = *uploadThreads
+		opts.ConcurrentStreamParts = true
+		size = -1
+	} else {
+		opts.DisableMultipart = true
+	}
+
+	reservation, limit, ok := reserveQuota(r, r.ContentLength)
+	if !ok {
+		http.Error(w, "507 Insufficient Storage", http.StatusInsufficientStorage)
+		return
+	}
+	if r.ContentLength < 0 && limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	m, objectKey := resolveKey(key)
+	info, err := m.client().PutObject(context.Background(), m.Bucket, objectKey, r.Body, size, opts)
+	if err != nil {
+		reservation.settle(0)
+	}
+	if isTooLarge(err) {
+		http.Error(w, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		log.Printf("文件上传失败: %v", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	reservation.settle(info.Size)
+	w.Header().Set("ETag", `"`+info.ETag+`"`)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// 创建空目录：以斜杠结尾的零字节对象
+func handleMkdir(w http.ResponseWriter, key string) {
+	key = strings.TrimSuffix(key, "/")
+	if key == "" {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key += "/"
+
+	// 目录已存在
+	m, objectKey := resolveKey(key)
+	if _, err := statObject(context.Background(), m, objectKey, minio.StatObjectOptions{}); err == nil {
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	_, err := m.client().PutObject(context.Background(), m.Bucket, objectKey, strings.NewReader(""), 0, minio.PutObjectOptions{
+		ContentType: "application/x-directory",
+	})
+	if err != nil {
+		log.Printf("目录创建失败: %v", err)
+		http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// 版本历史页面模板
+const versionsTemplate = `
+<!DOCTYPE html>
+<html>
+<head>
+    <title>Versions of {{.Path}}</title>
+    {{template "style"}}
+</head>
+<body>
+    <h1>Versions of {{.Path}}</h1>
+    {{with .Lock}}<p>{{if .LegalHold}}Legal hold is on. {{end}}{{if .RetainUntil}}Retained in {{.Mode}} mode until {{.RetainUntil.Format "2006-01-02 15:04:05"}}.{{end}}</p>{{end}}
+    <table>
+        <tr><th>Version ID</th><th>Size</th><th>Last Modified</th></tr>
+        {{range .Versions}}
+        <tr>
+            <td>{{if .IsDeleteMarker}}{{.VersionID}}{{else}}<a href="?versionId={{.VersionID}}">{{.VersionID}}</a>{{end}}{{if .IsLatest}} (latest){{end}}{{if .IsDeleteMarker}} (deleted){{end}}</td>
+            <td>{{if .IsDeleteMarker}}-{{else}}{{formatSize .Size}}{{end}}</td>
+            <td>{{.LastModified.Format "2006-01-02 15:04:05"}}</td>
+        </tr>
+        {{end}}
+    </table>
+</body>
+</html>`
+
+var versionsTmpl = newPageTemplate("versions", versionsTemplate)
+
+type ObjectVersion struct {
+	VersionID      string    `json:"versionId"`
+	Size           int64     `json:"size"`
+	ETag           string    `json:"etag,omitempty"`
+	LastModified   time.Time `json:"lastModified"`
+	IsLatest       bool      `json:"isLatest"`
+	IsDeleteMarker bool      `json:"isDeleteMarker"`
+}
+
+// 列出文件的历史版本，?format=json 时返回 JSON
+func handleVersions(w http.ResponseWriter, r *http.Request, key string) {
+	if key == "" {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+
+	var versions []ObjectVersion
+	for obj := range listObjects(context.Background(), minio.ListObjectsOptions{
+		Prefix:       key,
+		Recursive:    true,
+		WithVersions: true,
+	}) {
+		if obj.Err != nil {
+			log.Printf("版本列表错误: %v", obj.Err)
+			http.Error(w, "500 Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if obj.Key != key {
+			continue
+		}
+		versions = append(versions, ObjectVersion{
+			VersionID:      obj.VersionID,
+			Size:           obj.Size,
+			ETag:           obj.ETag,
+			LastModified:   obj.LastModified,
+			IsLatest:       obj.IsLatest,
+			IsDeleteMarker: obj.IsDeleteMarker,
+		})
+	}
+	if len(versions) == 0 {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(versions); err != nil {
+			log.Printf("响应写入失败: %v", err)
+		}
+		return
+	}
+
+	// 当前版本的锁定状态
+	var lock *ObjectLock
+	m, objectKey := resolveKey(key)
+	if objInfo, err := statObject(context.Background(), m, objectKey, minio.StatObjectOptions{}); err == nil {
+		lock = objectLock(objInfo)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := versionsTmpl.Execute(w, struct {
+		Path     string
+		Versions []ObjectVersion
+		Lock     *ObjectLock
+	}{
+		Path:     keyURL(key),
+		Versions: versions,
+		Lock:     lock,
+	})
+	if err != nil {
+		log.Printf("模板渲染失败: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	webhookURL      = flag.String("webhook-url", "", "The URL receiving download events as JSON arrays, disabled when empty")
+	webhookBatch    = flag.Int("webhook-batch", 100, "The maximum number of download events sent in one webhook request")
+	webhookInterval = flag.Duration("webhook-interval", 10*time.Second, "How often pending download events are sent to the webhook")
+	webhookRetries  = flag.Int("webhook-retries", 3, "The number of attempts to deliver a batch of download events")
+	webhookTimeout  = flag.Duration("webhook-timeout", 10*time.Second, "How long one webhook request may take")
+	webhookClient   *http.Client
+)
+
+// 待发送事件的上限，webhook 长时间不可用时丢弃最早的事件
+const maxPendingEvents = 10000
+
+type DownloadEvent struct {
+	Time     time.Time `json:"time"`
+	Key      string    `json:"key"`
+	Bytes    int64     `json:"bytes"`
+	Status   int       `json:"status"`
+	Client   string    `json:"client"`
+	Duration float64   `json:"duration"` // 秒
+	Aborted  bool      `json:"aborted,omitempty"`
+}
+
+var webhook struct {
+	sync.Mutex
+	pending []DownloadEvent
+	wake    chan struct{}
+}
+
+func startWebhook() {
+	if *webhookURL == "" {
+		return
+	}
+	webhookClient = &http.Client{Timeout: *webhookTimeout}
+	webhook.wake = make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(*webhookInterval)
+		for {
+			select {
+			case <-ticker.C:
+			case <-webhook.wake:
+			}
+			flushWebhook()
+		}
+	}()
+}
+
+// 文件下载结束，只有完整发送的下载计数，中途断开的作为 aborted 事件上报
+func downloadCompleted(r *http.Request, key string, rec *responseRecorder, start time.Time) {
+	if r.Method != http.MethodGet {
+		return
+	}
+	// 空文件没有写入，按默认的 200 计
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status < 200 || status >= 300 {
+		return
+	}
+	// 范围请求的 Content-Length 同样是本次应发送的长度
+	complete := true
+	if length, err := strconv.ParseInt(rec.Header().Get("Content-Length"), 10, 64); err == nil {
+		complete = rec.bytes == length
+	}
+	event := DownloadEvent{
+		Time:     start,
+		Key:      key,
+		Bytes:    rec.bytes,
+		Status:   status,
+		Client:   clientIP(r),
+		Duration: time.Since(start).Seconds(),
+		Aborted:  !complete,
+	}
+	if complete {
+		countDownload(key, status)
+	}
+	sendWebhook(event)
+}
+
+func sendWebhook(event DownloadEvent) {
+	if *webhookURL == "" {
+		return
+	}
+	webhook.Lock()
+	if len(webhook.pending) >= maxPendingEvents {
+		webhook.pending = webhook.pending[1:]
+	}
+	webhook.pending = append(webhook.pending, event)
+	full := len(webhook.pending) >= *webhookBatch
+	webhook.Unlock()
+
+	// 攒够一批立即发送
+	if full {
+		select {
+		case webhook.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func flushWebhook() {
+	for {
+		webhook.Lock()
+		n := min(len(webhook.pending), *webhookBatch)
+		batch := slices.Clone(webhook.pending[:n])
+		webhook.pending = webhook.pending[n:]
+		webhook.Unlock()
+		if n == 0 {
+			return
+		}
+
+		if err := postEvents(batch); err != nil {
+			log.Printf("下载事件发送失败: %v", err)
+			// 放回队首，下次重试
+			webhook.Lock()
+			webhook.pending = append(batch, webhook.pending...)
+			if over := len(webhook.pending) - maxPendingEvents; over > 0 {
+				webhook.pending = webhook.pending[over:]
+			}
+			webhook.Unlock()
+			return
+		}
+	}
+}
+
+// 发送一批事件，失败时按指数退避重试
+func postEvents(events []DownloadEvent) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err = postJSON(*webhookURL, body)
+		if err == nil || attempt >= *webhookRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func postJSON(url string, body []byte) error {
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
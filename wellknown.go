@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	wellKnownFiles  = valueMap{}
+	wellKnownDir    = flag.String("well-known-dir", "", "A local directory served as /.well-known/, e.g. the webroot of ACME HTTP-01 challenges")
+	wellKnownPrefix = flag.String("well-known-prefix", "", "The key prefix looked up for /.well-known/ paths before the bucket's own .well-known/")
+)
+
+func init() {
+	flag.Var(wellKnownFiles, "well-known", "The name=path of a local file served as /.well-known/name, like security.txt, can be repeated")
+}
+
+// /.well-known/ 依次查找本地文件、本地目录、指定前缀，最后才是存储桶中的同名对象；
+// 本地文件不经过维护模式、主机名重定向与后端就绪检查，ACME 验证始终可用
+func withWellKnown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/.well-known/")
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || name == "" || strings.HasPrefix(name, "/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if file, ok := wellKnownFiles[name]; ok {
+			http.ServeFile(w, r, file)
+			return
+		}
+		if *wellKnownDir != "" {
+			file := filepath.Join(*wellKnownDir, filepath.FromSlash(name))
+			if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+				http.ServeFile(w, r, file)
+				return
+			}
+		}
+		if *wellKnownPrefix != "" && backendReady.Load() {
+			key := strings.TrimPrefix(*wellKnownPrefix, "/") + name
+			if findMount(key) != nil && fileExists(key) {
+				r = r.Clone(r.Context())
+				r.URL.Path, r.URL.RawPath = "/"+key, ""
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
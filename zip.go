@@ -0,0 +1,157 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+)
+
+var (
+	zipDownload = flag.Bool("zip-download", true, "Allow downloading selected files and directories of a listing as one ZIP")
+	zipMaxFiles = flag.Int("zip-max-files", 1000, "The most objects in one ZIP download, 0 means unlimited")
+	zipMaxSize  = flag.Int64("zip-max-size", 0, "The most bytes in one ZIP download before compression, 0 means unlimited")
+)
+
+type zipItem struct {
+	key  string
+	name string // ZIP 中的路径
+	info minio.ObjectInfo
+}
+
+// 将 ?zip&name=a.txt&name=sub/ 选中的文件与目录打包下载，name 相对于 prefix
+func handleZip(w http.ResponseWriter, r *http.Request, prefix string) {
+	if !*zipDownload || (prefix != "" && !strings.HasSuffix(prefix, "/")) {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+	names := r.URL.Query()["name"]
+	if len(names) == 0 {
+		http.Error(w, "400 Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var items []zipItem
+	var total int64
+	for _, name := range names {
+		clean := path.Clean("/" + name)[1:]
+		if clean == "" || strings.HasPrefix(name, "/") || clean != strings.TrimSuffix(name, "/") {
+			http.Error(w, "400 Bad Request", http.StatusBadRequest)
+			return
+		}
+		key := prefix + name
+		if !checkAccess(w, r, key, false) {
+			return
+		}
+		found, err := collectZipItems(r, prefix, key)
+		if err != nil {
+			log.Printf("打包文件列表错误: %v", err)
+			http.Error(w, "502 Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		for _, item := range found {
+			total += item.info.Size
+		}
+		items = append(items, found...)
+		if (*zipMaxFiles > 0 && len(items) > *zipMaxFiles) || (*zipMaxSize > 0 && total > *zipMaxSize) {
+			http.Error(w, "413 Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+	}
+	if len(items) == 0 {
+		http.Error(w, "404 Not Found", http.StatusNotFound)
+		return
+	}
+
+	release, ok := acquireDownload(r)
+	if !ok {
+		rejectDownload(w, r)
+		return
+	}
+	defer release()
+
+	filename := path.Base(strings.TrimSuffix(prefix, "/"))
+	if len(names) == 1 {
+		filename = path.Base(strings.TrimSuffix(names[0], "/"))
+	}
+	if filename == "." || filename == "" {
+		filename = "download"
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename + ".zip"}))
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := writeZip(r.Context(), w, items); err != nil {
+		log.Printf("打包下载失败: %v", err)
+	}
+}
+
+// 列出 key 对应的文件，或目录下全部可读取的文件
+func collectZipItems(r *http.Request, prefix, key string) ([]zipItem, error) {
+	if findMount(key) == nil {
+		return nil, nil
+	}
+	if !strings.HasSuffix(key, "/") {
+		m, objectKey := resolveKey(key)
+		info, err := statObject(r.Context(), m, objectKey, minio.StatObjectOptions{})
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, nil
+		}
+		if err != nil || !zipIncluded(r, key, info) {
+			return nil, err
+		}
+		return []zipItem{{key: key, name: strings.TrimPrefix(key, prefix), info: info}}, nil
+	}
+
+	var items []zipItem
+	for obj := range listObjects(r.Context(), minio.ListObjectsOptions{Prefix: key, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if strings.HasSuffix(obj.Key, "/") || isAccessFile(obj.Key) || !keyListable(obj.Key) || !zipIncluded(r, obj.Key, obj) {
+			continue
+		}
+		items = append(items, zipItem{key: obj.Key, name: strings.TrimPrefix(obj.Key, prefix), info: obj})
+	}
+	return items, nil
+}
+
+// 跳过归档存储、被过滤或无权读取的文件
+func zipIncluded(r *http.Request, key string, info minio.ObjectInfo) bool {
+	return !isArchived(info.StorageClass) &&
+		fileAllowed(key, objectContentType(key, info.ContentType)) &&
+		canRead(r, key)
+}
+
+// 逐个读取对象写入 ZIP，不压缩以减少 CPU 占用
+func writeZip(ctx context.Context, w io.Writer, items []zipItem) error {
+	zw := zip.NewWriter(w)
+	for _, item := range items {
+		object, _, err := openObject(ctx, item.key, "")
+		if err != nil {
+			return fmt.Errorf("%s: %w", item.key, err)
+		}
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     item.name,
+			Method:   zip.Store,
+			Modified: item.info.LastModified,
+		})
+		if err == nil {
+			_, err = copyBuffered(entry, object)
+		}
+		object.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}